@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+// NoArgs returns an error if there are any positional arguments. It can be
+// used as the ValidateArgs(args []string) error method of the commands, same
+// as the other validators. Validation errors wrap [ErrInvalidArgs].
+func NoArgs(args []string) error {
+	if len(args) != 0 {
+		return newParseError(ErrInvalidArgs, "command takes no arguments, received %d", len(args))
+	}
+	return nil
+}
+
+// ExactArgs returns a validator that requires exactly n positional arguments.
+//
+// Example:
+//
+//	func (c *CopyCommand) ValidateArgs(args []string) error {
+//	    return cli.ExactArgs(2)(args)
+//	}
+func ExactArgs(n int) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != n {
+			return newParseError(ErrInvalidArgs, "command takes %d %s, received %d", n, pluralArgs(n), len(args))
+		}
+		return nil
+	}
+}
+
+// MinimumNArgs returns a validator that requires at least n positional
+// arguments.
+func MinimumNArgs(n int) func(args []string) error {
+	return func(args []string) error {
+		if len(args) < n {
+			return newParseError(ErrInvalidArgs, "command takes at least %d %s, received %d", n, pluralArgs(n), len(args))
+		}
+		return nil
+	}
+}
+
+// MaximumNArgs returns a validator that allows at most n positional arguments.
+func MaximumNArgs(n int) func(args []string) error {
+	return func(args []string) error {
+		if len(args) > n {
+			return newParseError(ErrInvalidArgs, "command takes at most %d %s, received %d", n, pluralArgs(n), len(args))
+		}
+		return nil
+	}
+}
+
+// RangeArgs returns a validator that requires between min and max positional
+// arguments, inclusive.
+func RangeArgs(min, max int) func(args []string) error {
+	return func(args []string) error {
+		if len(args) < min || len(args) > max {
+			return newParseError(ErrInvalidArgs, "command takes %d to %d arguments, received %d", min, max, len(args))
+		}
+		return nil
+	}
+}
+
+func pluralArgs(n int) string {
+	if n == 1 {
+		return "argument"
+	}
+	return "arguments"
+}
+
+// validateArgs checks the positional arguments if the command implements the
+// ValidateArgs(args []string) error method.
+func validateArgs(c Command, args []string) error {
+	if v, ok := optional(c).(interface{ ValidateArgs([]string) error }); ok {
+		return v.ValidateArgs(args)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestArgValidators(t *testing.T) {
+	tests := []struct {
+		validate func([]string) error
+		nargs    int
+		ok       bool
+	}{
+		{NoArgs, 0, true},
+		{NoArgs, 1, false},
+		{ExactArgs(2), 2, true},
+		{ExactArgs(2), 1, false},
+		{MinimumNArgs(1), 1, true},
+		{MinimumNArgs(1), 0, false},
+		{MaximumNArgs(1), 1, true},
+		{MaximumNArgs(1), 2, false},
+		{RangeArgs(1, 2), 2, true},
+		{RangeArgs(1, 2), 3, false},
+	}
+	for i, tt := range tests {
+		err := tt.validate(make([]string, tt.nargs))
+		if tt.ok && err != nil {
+			t.Errorf("%d: got error %v, want nil", i, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidArgs) {
+			t.Errorf("%d: got error %v, want ErrInvalidArgs", i, err)
+		}
+	}
+}
+
+type noArgsCmd struct {
+	*TestCmd
+}
+
+func (c *noArgsCmd) ValidateArgs(args []string) error {
+	return NoArgs(args)
+}
+
+func TestValidateArgs(t *testing.T) {
+	ctx := context.Background()
+
+	list := &noArgsCmd{newTestCmd("list")}
+	db := NewGroup("db", "manage database", list)
+
+	if err := Run(ctx, []Command{db}, []string{"db", "list"}); err != nil {
+		t.Fatal(err)
+	}
+	list.args = nil
+	err := Run(ctx, []Command{db}, []string{"db", "list", "extra"})
+	if !errors.Is(err, ErrInvalidArgs) {
+		t.Fatalf("want ErrInvalidArgs, got %v", err)
+	}
+	if want := "db list: command takes no arguments, received 1"; err.Error() != want {
+		t.Fatalf("got message %q, want %q", err.Error(), want)
+	}
+	if list.args != nil {
+		t.Fatalf("want command not to run")
+	}
+}
+
+type exactArgsCmd struct {
+	*TestCmd
+}
+
+func (c *exactArgsCmd) ValidateArgs(args []string) error {
+	return ExactArgs(2)(args)
+}
+
+func TestStrictTerminatedArgs(t *testing.T) {
+	ctx := context.Background()
+
+	cp := &exactArgsCmd{newTestCmd("cp")}
+	cp.flags.Bool("r", false, "copy recursively")
+	echo := newTestCmd("echo")
+	cmds := []Command{cp, echo}
+
+	if err := Run(ctx, cmds, []string{"cp", "--", "a", "-r"}); err != nil {
+		t.Fatalf("want flag-like arguments after \"--\" by default, got %v", err)
+	}
+
+	opts := &Options{StrictTerminatedArgs: true}
+	err := opts.Run(ctx, cmds, []string{"cp", "--", "a", "-r"})
+	if !errors.Is(err, ErrInvalidArgs) {
+		t.Fatalf("want ErrInvalidArgs, got %v", err)
+	}
+	if want := `cp: argument -r after "--" looks like a flag; place the flags before "--"`; err.Error() != want {
+		t.Fatalf("got message %q, want %q", err.Error(), want)
+	}
+
+	for _, args := range [][]string{{"cp", "-r", "--", "a", "-1"}, {"cp", "a", "-x"}} {
+		if err := opts.Run(ctx, cmds, args); err != nil {
+			t.Errorf("Run(%q): %v", args, err)
+		}
+	}
+	if err := opts.Run(ctx, cmds, []string{"echo", "--", "-n"}); err != nil {
+		t.Fatalf("want no check for commands without ValidateArgs, got %v", err)
+	}
+
+	// arguments are checked from the "--" for the default command too
+	opts = &Options{StrictTerminatedArgs: true, DefaultCommand: "cp", PreserveTerminator: true}
+	if err := opts.Run(ctx, cmds, []string{"--", "a", "-r"}); !errors.Is(err, ErrInvalidArgs) {
+		t.Fatalf("want ErrInvalidArgs for the default command, got %v", err)
+	}
+	if err := opts.Run(ctx, cmds, []string{"--", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"io"
+	"os"
+)
+
+// ColorMode selects when the documentation output is colorized.
+type ColorMode int
+
+const (
+	// ColorAuto colorizes the output only when it is written to a terminal
+	// and the NO_COLOR environment variable is not set. Setting the
+	// CLICOLOR_FORCE environment variable to a value other than "0" forces the
+	// colors even when the output is not a terminal.
+	ColorAuto ColorMode = iota
+
+	// ColorAlways always colorizes the output.
+	ColorAlways
+
+	// ColorNever never colorizes the output.
+	ColorNever
+)
+
+const (
+	ansiBold  = "\x1b[1m"
+	ansiCyan  = "\x1b[36m"
+	ansiReset = "\x1b[0m"
+)
+
+// palette applies the ANSI colors to the parts of the documentation. Zero
+// value disables the colors.
+type palette struct {
+	enabled bool
+}
+
+func (p palette) apply(code, s string) string {
+	if !p.enabled || len(s) == 0 {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// header colorizes the section headers, like "Usage:".
+func (p palette) header(s string) string { return p.apply(ansiBold, s) }
+
+// name colorizes the command and flag names.
+func (p palette) name(s string) string { return p.apply(ansiCyan, s) }
+
+// isTerminal reports true if the reader or writer is a character device, like
+// a terminal. It is a variable so that tests can simulate a terminal.
+var isTerminal = func(v any) bool {
+	f, ok := v.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor reports true if the output to the writer should be colorized. An
+// explicit ColorAlways or ColorNever mode takes precedence over the
+// environment. In the ColorAuto mode, colors are disabled when the NO_COLOR
+// environment variable is set to any value, forced when the CLICOLOR_FORCE
+// environment variable is set to a value other than "0" and are enabled
+// otherwise only when the writer is a terminal.
+func useColor(mode ColorMode, w io.Writer) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if v := os.Getenv("CLICOLOR_FORCE"); len(v) > 0 && v != "0" {
+		return true
+	}
+	return isTerminal(w)
+}
+
+// palette returns the color palette for the output to the writer.
+func (opts *Options) palette(w io.Writer) palette {
+	return palette{enabled: useColor(opts.Color, w)}
+}
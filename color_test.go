@@ -0,0 +1,72 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUseColor(t *testing.T) {
+	saved := isTerminal
+	defer func() { isTerminal = saved }()
+	// Register the variables with t.Setenv so that they are restored.
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("CLICOLOR_FORCE", "")
+
+	testcases := []struct {
+		mode    ColorMode
+		noColor string
+		force   string
+		tty     bool
+		want    bool
+	}{
+		{mode: ColorAuto, tty: true, want: true},
+		{mode: ColorAuto, tty: false, want: false},
+		{mode: ColorAuto, noColor: "1", tty: true, want: false},
+		{mode: ColorAuto, force: "1", tty: false, want: true},
+		{mode: ColorAuto, force: "0", tty: false, want: false},
+		{mode: ColorAuto, noColor: "1", force: "1", tty: true, want: false},
+		{mode: ColorAlways, noColor: "1", tty: false, want: true},
+		{mode: ColorNever, force: "1", tty: true, want: false},
+	}
+	for i, tc := range testcases {
+		if len(tc.noColor) > 0 {
+			t.Setenv("NO_COLOR", tc.noColor)
+		} else {
+			os.Unsetenv("NO_COLOR")
+		}
+		if len(tc.force) > 0 {
+			t.Setenv("CLICOLOR_FORCE", tc.force)
+		} else {
+			os.Unsetenv("CLICOLOR_FORCE")
+		}
+		isTerminal = func(any) bool { return tc.tty }
+		if got := useColor(tc.mode, io.Discard); got != tc.want {
+			t.Errorf("%d: useColor: got %v, want %v", i, got, tc.want)
+		}
+	}
+}
+
+func TestNoColorHelp(t *testing.T) {
+	saved := isTerminal
+	defer func() { isTerminal = saved }()
+	isTerminal = func(any) bool { return true }
+
+	t.Setenv("NO_COLOR", "1")
+
+	start := newTestCmd("start")
+	start.flags.Int("port", 8080, "server port")
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout}
+	if err := opts.Run(context.Background(), []Command{start}, []string{"help", "start"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := stdout.String(); strings.Contains(out, "\x1b[") {
+		t.Fatalf("want no colors with NO_COLOR, got %q", out)
+	}
+}
@@ -18,6 +18,21 @@
 // or custom types implementing the [Command] interface. Execute the CLI by passing
 // commands to [Run] with command-line arguments.
 //
+// Flags are parsed in the order they appear in the command-line. A flag is
+// accepted only after the command or the group that defines it is selected,
+// and it is set on the flag set of the deepest selected command that defines
+// it. Flags of the top-level and the parent groups stay available to all
+// subcommands, so "app -global server -group start -local" sets each flag at
+// its own level, but "app -local server start" fails, because the "start"
+// command is not yet selected when "-local" appears. Such errors name the
+// commands that define the flag.
+//
+// A "--" argument stops both the flag parsing and the subcommand resolution,
+// and the remaining arguments are passed to the selected command as is. It
+// must follow a command that runs, so "app jobs -- pause" fails when "jobs"
+// is a group, instead of running "pause", unless a default command is
+// selected at the top-level; see [Options.DefaultCommand].
+//
 // Example (Function-based command):
 //
 //	var listFlags flag.FlagSet
@@ -65,7 +80,11 @@ package cli
 import (
 	"context"
 	"flag"
-	"os"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
 )
 
 // CmdFunc defines the behavior of a CLI command. It accepts a context for
@@ -88,6 +107,38 @@ type CmdFunc func(ctx context.Context, args []string) error
 // Commands may implement optional interfaces for documentation:
 //   - Purpose() string: Returns a brief description.
 //   - Description() string: Returns detailed help text.
+//   - Deprecated() string: Returns a deprecation message, which is printed as
+//     a warning when the command is used.
+//   - RemoveIn() string: Returns the version or the date, like "v2.0", when a
+//     deprecated command is removed, which is included in the warning.
+//   - Usage() string: Returns the usage line that follows the command path in
+//     the help output, as in "[options] <file>...".
+//   - Group() string: Returns a section title, like "Advanced Commands", under
+//     which the command is listed in the help output of its parent.
+//   - ArgNames() []string: Returns the positional argument names, which
+//     replace the generic "<args>" in the usage line. Names are printed as
+//     the required arguments, as in "<src>", unless they are bracketed, as
+//     in "[dst]" for an optional argument. A nil or empty list omits the
+//     arguments from the usage line, for the commands that take no
+//     arguments, as with the [NoArgs] validator.
+//   - Examples() []string: Returns the usage examples, like "myapp copy a.txt
+//     b.txt", which are printed under an "Examples:" section in the help
+//     output. An example can span multiple lines.
+//
+// Commands may also implement optional interfaces to customize parsing and
+// execution:
+//   - PassThrough() bool: Stops flag parsing at the first non-flag argument
+//     even when [Options.InterspersedFlags] is set, so that the remaining
+//     arguments can be forwarded as is.
+//   - Timeout() time.Duration: Returns the maximum run time for the command.
+//     Also see [TimeoutVar].
+//   - ValidateArgs(args []string) error: Checks the positional arguments before
+//     the command runs. Also see [NoArgs] and [ExactArgs]. The validator is
+//     not used for the usage line, which shows the generic "<args>" unless
+//     ArgNames is implemented.
+//
+// Types with the two value Command() (*flag.FlagSet, CmdFunc) method can be
+// converted with [AdaptCommand].
 //
 // Create commands using NewCommand, NewGroup, or custom types.
 //
@@ -110,13 +161,14 @@ type Command interface {
 }
 
 type basicCmd struct {
+	name    string
 	cmd     CmdFunc
 	fset    *flag.FlagSet
 	purpose string
 }
 
 func (v *basicCmd) Command() (string, *flag.FlagSet, CmdFunc) {
-	return v.fset.Name(), v.fset, v.cmd
+	return v.name, v.fset, v.cmd
 }
 
 func (v *basicCmd) Purpose() string {
@@ -125,10 +177,11 @@ func (v *basicCmd) Purpose() string {
 
 // NewCommand creates a function-based command with the specified name,
 // function, flags, and a single line purpose. The flag.FlagSet is optional; if
-// nil, no flags are supported. The package overrides flag.FlagSet's default
-// error handling.
+// nil, no flags are supported. The error handling of the flag.FlagSet is
+// preserved, but flag parsing errors are returned by [Run] unless
+// [Options.FlagErrorHandling] is set.
 //
-// Returns nil if command name is empty.
+// Returns nil if command name is invalid; see [CheckName].
 //
 // Example:
 //
@@ -140,15 +193,262 @@ func (v *basicCmd) Purpose() string {
 //	}
 //	command := cli.NewCommand("greet", cmd, &flags, "Greet a user")
 func NewCommand(name string, cmd CmdFunc, fset *flag.FlagSet, purpose string) Command {
-	if len(name) == 0 {
+	if CheckName(name) != nil {
 		return nil
 	}
 	if fset == nil {
 		fset = flag.NewFlagSet(name, flag.ContinueOnError)
 	} else {
-		fset.Init(name, flag.ContinueOnError)
+		fset.Init(name, fset.ErrorHandling())
+	}
+	return &basicCmd{name: name, cmd: cmd, fset: fset, purpose: purpose}
+}
+
+// NewCommandWithSharedFlags is similar to [NewCommand], but the name of the
+// flag.FlagSet is not changed, so that the same flag.FlagSet can be shared by
+// multiple commands or named differently from the command. The command name
+// is used for the lookup and in the documentation. The flag.FlagSet is
+// required.
+//
+// Returns nil if command name is invalid; see [CheckName].
+//
+// Example:
+//
+//	common := flag.NewFlagSet("common", flag.ContinueOnError)
+//	addr := common.String("addr", "localhost:8080", "server address")
+//	getCmd := cli.NewCommandWithSharedFlags("get", getFunc, common, "Get a key")
+//	putCmd := cli.NewCommandWithSharedFlags("put", putFunc, common, "Put a key")
+func NewCommandWithSharedFlags(name string, cmd CmdFunc, fset *flag.FlagSet, purpose string) Command {
+	if CheckName(name) != nil || fset == nil {
+		return nil
+	}
+	return &basicCmd{name: name, cmd: cmd, fset: fset, purpose: purpose}
+}
+
+type lazyCmd struct {
+	name    string
+	purpose string
+	build   func() Command
+
+	once  sync.Once
+	built Command
+}
+
+func (v *lazyCmd) get() Command {
+	v.once.Do(func() {
+		v.built = v.build()
+	})
+	return v.built
+}
+
+func (v *lazyCmd) Command() (string, *flag.FlagSet, CmdFunc) {
+	_, fs, fn := v.get().Command()
+	return v.name, fs, fn
+}
+
+func (v *lazyCmd) Purpose() string {
+	return v.purpose
+}
+
+// NewLazyCommand creates a command with the specified name and purpose, whose
+// implementation is constructed by the build function only when the command
+// is selected from the command-line. This avoids the construction cost for
+// commands that are not used. The build function is called at most once and
+// must return a non-nil Command, which may also be a group. Name of the built
+// command is ignored.
+//
+// Since the listings of the parent group do not construct the command, they
+// show only the name and purpose given here: the Deprecated, Group and
+// Examples methods of the built command are not used, and a lazy group is
+// listed along with the other commands instead of the groups. The help for
+// the command itself is taken from the built command.
+//
+// Returns nil if command name is invalid; see [CheckName].
+//
+// Example:
+//
+//	cmd := cli.NewLazyCommand("index", "Rebuild the index", func() cli.Command {
+//	    return newIndexCommand(loadConfig())
+//	})
+func NewLazyCommand(name, purpose string, build func() Command) Command {
+	if CheckName(name) != nil || build == nil {
+		return nil
+	}
+	return &lazyCmd{name: name, purpose: purpose, build: build}
+}
+
+// FlagSetCommand is the two value form of the [Command] interface, where the
+// command name is taken from the flag.FlagSet. Use [AdaptCommand] to convert
+// it into a Command.
+type FlagSetCommand interface {
+	Command() (*flag.FlagSet, CmdFunc)
+}
+
+type adaptedCmd struct {
+	impl any
+	fset *flag.FlagSet
+	fun  CmdFunc
+}
+
+func (v *adaptedCmd) Command() (string, *flag.FlagSet, CmdFunc) {
+	return v.fset.Name(), v.fset, v.fun
+}
+
+// AdaptCommand converts a command that implements the two value form of the
+// Command method into a [Command]. The Command method is called only once and
+// the command name is taken from the returned flag.FlagSet. Optional
+// interfaces, like Purpose and Description, are looked up on the input.
+//
+// Returns nil if the flag.FlagSet is nil or its name is invalid; see
+// [CheckName].
+//
+// Example:
+//
+//	type List struct{ keyRe string }
+//	func (c *List) Command() (*flag.FlagSet, cli.CmdFunc) {
+//	    fset := flag.NewFlagSet("list", flag.ContinueOnError)
+//	    fset.StringVar(&c.keyRe, "key-regexp", "", "regular expression to pick keys")
+//	    return fset, c.Run
+//	}
+//	cmds := []cli.Command{cli.AdaptCommand(new(List))}
+func AdaptCommand(c FlagSetCommand) Command {
+	if c == nil {
+		return nil
+	}
+	fset, fun := c.Command()
+	if fset == nil || CheckName(fset.Name()) != nil {
+		return nil
+	}
+	return &adaptedCmd{impl: c, fset: fset, fun: fun}
+}
+
+// FromFunc creates a command with the specified name, purpose and run
+// function, whose flags are defined by the setFlags function on a new
+// flag.FlagSet. The setFlags function is optional.
+//
+// Returns nil if command name is invalid; see [CheckName].
+//
+// Example:
+//
+//	var force bool
+//	cmd := cli.FromFunc("clean", "Remove build outputs", runClean, func(fset *flag.FlagSet) {
+//	    fset.BoolVar(&force, "force", false, "remove without confirmation")
+//	})
+func FromFunc(name, purpose string, run func(ctx context.Context, args []string) error, setFlags func(*flag.FlagSet)) Command {
+	if CheckName(name) != nil || run == nil {
+		return nil
+	}
+	fset := flag.NewFlagSet(name, flag.ContinueOnError)
+	if setFlags != nil {
+		setFlags(fset)
+	}
+	return NewCommand(name, run, fset, purpose)
+}
+
+type flagSetter interface {
+	SetFlags(*flag.FlagSet)
+}
+
+// FromStruct creates a command with the specified name from a pointer to a
+// struct that implements the Run(ctx context.Context, args []string) error
+// method. If the struct implements the SetFlags(*flag.FlagSet) method, it
+// defines the command flags; otherwise, SetFlags methods of the exported
+// embedded struct fields are called in their field order, which allows
+// composing the flags from reusable structs. Optional interfaces, like
+// Purpose and Description, are looked up on the input.
+//
+// Returns nil if command name is invalid or the input is not a pointer to a
+// struct with the Run method.
+//
+// Example:
+//
+//	type ClientFlags struct{ Port int }
+//	func (cf *ClientFlags) SetFlags(fset *flag.FlagSet) {
+//	    fset.IntVar(&cf.Port, "port", 10000, "TCP port number")
+//	}
+//	type Status struct{ ClientFlags }
+//	func (c *Status) Purpose() string { return "Print the server status" }
+//	func (c *Status) Run(ctx context.Context, args []string) error { ... }
+//
+//	cmd := cli.FromStruct("status", new(Status)) // has the -port flag
+func FromStruct(name string, v any) Command {
+	if CheckName(name) != nil {
+		return nil
+	}
+	r, ok := v.(interface {
+		Run(context.Context, []string) error
+	})
+	if !ok {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	fset := flag.NewFlagSet(name, flag.ContinueOnError)
+	setStructFlags(rv, fset)
+	return &adaptedCmd{impl: v, fset: fset, fun: r.Run}
+}
+
+// setStructFlags calls the SetFlags method of a struct pointer, or of its
+// embedded fields when the struct doesn't have one.
+func setStructFlags(rv reflect.Value, fset *flag.FlagSet) {
+	if v, ok := rv.Interface().(flagSetter); ok {
+		v.SetFlags(fset)
+		return
+	}
+	sv := rv.Elem()
+	for i := 0; i < sv.NumField(); i++ {
+		field, fv := sv.Type().Field(i), sv.Field(i)
+		if !field.Anonymous || !field.IsExported() {
+			continue
+		}
+		switch {
+		case fv.Kind() == reflect.Struct:
+			setStructFlags(fv.Addr(), fset)
+		case fv.Kind() == reflect.Pointer && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct:
+			setStructFlags(fv, fset)
+		}
 	}
-	return &basicCmd{cmd: cmd, fset: fset, purpose: purpose}
+}
+
+// optional returns the value that implements the optional interfaces of a
+// command, which is the input for the commands created by AdaptCommand and
+// FromStruct.
+func optional(c Command) any {
+	if v, ok := c.(*adaptedCmd); ok {
+		return v.impl
+	}
+	return c
+}
+
+// cmdName returns the name of a command without constructing the lazy
+// commands.
+func cmdName(c Command) string {
+	if v, ok := c.(*lazyCmd); ok {
+		return v.name
+	}
+	name, _, _ := c.Command()
+	return name
+}
+
+// CheckName returns a non-nil error if the input is not a valid command name.
+// Command names must be non-empty and must not contain white space or path
+// separators nor start with a dash.
+func CheckName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("command name cannot be empty")
+	}
+	if name[0] == '-' {
+		return fmt.Errorf("command name %q cannot start with a dash", name)
+	}
+	if strings.ContainsFunc(name, unicode.IsSpace) {
+		return fmt.Errorf("command name %q cannot contain white space", name)
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("command name %q cannot contain path separators", name)
+	}
+	return nil
 }
 
 // Run executes the CLI, parsing arguments to invoke a command from the provided
@@ -161,18 +461,43 @@ func NewCommand(name string, cmd CmdFunc, fset *flag.FlagSet, purpose string) Co
 //	cmd := cli.NewCommand("version", versionCmd, nil, "Display version")
 //	err := cli.Run(context.Background(), []cli.Command{cmd}, os.Args)
 func Run(ctx context.Context, cmds []Command, args []string) error {
-	if cmds == nil {
-		return os.ErrInvalid
-	}
-	root := groupCmd{
-		flags:   flag.CommandLine,
-		subcmds: cmds,
-	}
-	// If user passes os.Args, turn it into os.Args[1:] instead.
-	if len(args) != 0 {
-		if &args[0] == &os.Args[0] {
-			args = os.Args[1:]
-		}
-	}
-	return root.run(ctx, args)
+	return new(Options).Run(ctx, cmds, args)
+}
+
+// Resolve parses the command-line arguments against the commands, without
+// executing any command. Returns the resolved command path, excluding the
+// program name, and the remaining positional arguments. If the arguments
+// select a built-in command, special holds its default name: "help", "flags"
+// or "commands".
+//
+// Flag values from the command-line are set as a side effect, same as Run.
+// Required flags and flag constraints are not checked, because they may be
+// satisfied by the config file, which is only applied by Run, so Resolve never
+// prompts for the flag values.
+//
+// Example:
+//
+//	path, args, _, err := cli.Resolve(cmds, []string{"db", "scan", "prefix"})
+//	// path is ["db", "scan"] and args is ["prefix"]
+func Resolve(cmds []Command, args []string) (path []string, remaining []string, special string, err error) {
+	return new(Options).Resolve(cmds, args)
+}
+
+// RunCapture is similar to [Run], but returns the documentation and the
+// diagnostic messages printed by the package as strings, instead of writing
+// them to os.Stdout and os.Stderr. It is meant for the tests that check the
+// help and commands output. Output written by the commands themselves is not
+// captured.
+//
+// Example:
+//
+//	stdout, _, err := cli.RunCapture(ctx, cmds, []string{"help", "db"})
+//	if !strings.Contains(stdout, "Usage:") {
+//	    t.Errorf("want usage, got %q", stdout)
+//	}
+func RunCapture(ctx context.Context, cmds []Command, args []string) (stdout, stderr string, err error) {
+	var outb, errb strings.Builder
+	opts := &Options{Stdout: &outb, Stderr: &errb}
+	err = opts.Run(ctx, cmds, args)
+	return outb.String(), errb.String(), err
 }
@@ -0,0 +1,124 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readConfig reads flag names and values from a config file. Files with the
+// ".json" extension must hold a JSON object, where array values are expanded
+// into multiple values for the repeatable flags. Other files are read as flat
+// "name = value" lines, which is a subset of TOML; empty lines and lines
+// starting with '#' are ignored.
+func readConfig(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := make(map[string][]string)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		// numbers are decoded as json.Number to keep their text, so that
+		// 1000000 is not turned into 1e+06
+		var m map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&m); err != nil {
+			return nil, fmt.Errorf("could not parse config file %q: %w", path, err)
+		}
+		for k, v := range m {
+			if vs, ok := v.([]any); ok {
+				for _, v := range vs {
+					config[k] = append(config[k], fmt.Sprint(v))
+				}
+				continue
+			}
+			config[k] = []string{fmt.Sprint(v)}
+		}
+		return config, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("could not parse config file %q at line %d", path, lineno)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if s, err := strconv.Unquote(v); err == nil {
+			v = s
+		}
+		config[k] = append(config[k], v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyConfig sets the flag values from the config and returns the flags that
+// are set. Flags are looked up in the flag sets in the reverse order, so that
+// the last flag set takes precedence. Flags for which skip returns true and
+// the unknown names are ignored.
+func applyConfig(fsets []*flag.FlagSet, config map[string][]string, skip func(*flag.Flag) bool) ([]*flag.Flag, error) {
+	var configured []*flag.Flag
+	for name, values := range config {
+		var f *flag.Flag
+		var fset *flag.FlagSet
+		for i := len(fsets) - 1; i >= 0 && f == nil; i-- {
+			if f = fsets[i].Lookup(name); f != nil {
+				f, fset = canonicalFlag(fsets[i], f), fsets[i]
+			}
+		}
+		if f == nil || skip(f) {
+			continue
+		}
+		for _, v := range values {
+			if err := f.Value.Set(v); err != nil {
+				return configured, fmt.Errorf("invalid config value %q for flag -%s: %w", v, name, err)
+			}
+			if err := validateFlag(fset, f, v); err != nil {
+				return configured, err
+			}
+		}
+		configured = append(configured, f)
+	}
+	return configured, nil
+}
+
+// LoadDefaults sets the flag values from a config file, which is useful to
+// load persistent user preferences. Config files with the ".json" extension
+// must hold a JSON object with the flag names as the keys. Otherwise, the file
+// must hold "name = value" lines, which is a flat subset of TOML. Unknown
+// names are ignored.
+//
+// LoadDefaults must be called before the command-line is parsed, so that the
+// command-line flags override the config values. Also see
+// [Options.ConfigFlag].
+//
+// Example:
+//
+//	if err := cli.LoadDefaults(fset, filepath.Join(home, ".myapp.toml")); err != nil {
+//	    return err
+//	}
+func LoadDefaults(fset *flag.FlagSet, path string) error {
+	config, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	_, err = applyConfig([]*flag.FlagSet{fset}, config, func(*flag.Flag) bool { return false })
+	return err
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadDefaults(t *testing.T) {
+	dir := t.TempDir()
+	tomlPath := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(tomlPath, []byte("# preferences\nformat = \"json\"\nlimit = 20\n\nunknown = x\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"format": "yaml", "limit": 30, "header": ["a", "b"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fset := flag.NewFlagSet("list", flag.ContinueOnError)
+	format := fset.String("format", "text", "output format")
+	limit := fset.Int("limit", 10, "maximum number of items")
+	var headers []string
+	StringSliceVar(fset, &headers, "header", "request header")
+
+	if err := LoadDefaults(fset, tomlPath); err != nil {
+		t.Fatal(err)
+	}
+	if *format != "json" || *limit != 20 {
+		t.Fatalf("got format %q and limit %d", *format, *limit)
+	}
+	if err := LoadDefaults(fset, jsonPath); err != nil {
+		t.Fatal(err)
+	}
+	if *format != "yaml" || *limit != 30 || !slices.Equal(headers, []string{"a", "b"}) {
+		t.Fatalf("got format %q, limit %d and headers %q", *format, *limit, headers)
+	}
+	if err := LoadDefaults(fset, filepath.Join(dir, "missing.toml")); !os.IsNotExist(err) {
+		t.Fatalf("want not exist error, got %v", err)
+	}
+
+	// Large numbers keep their text.
+	if err := os.WriteFile(jsonPath, []byte(`{"format": 1000000}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDefaults(fset, jsonPath); err != nil {
+		t.Fatal(err)
+	}
+	if *format != "1000000" {
+		t.Fatalf("want format 1000000, got %q", *format)
+	}
+
+	// Config values are checked by the validators.
+	if err := os.WriteFile(jsonPath, []byte(`{"limit": 1000}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFlagValidator(fset, "limit", func(v string) error {
+		if len(v) > 3 {
+			return errors.New("too large")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDefaults(fset, jsonPath); !errors.Is(err, ErrInvalidFlagValue) {
+		t.Fatalf("want ErrInvalidFlagValue, got %v", err)
+	}
+}
+
+func TestConfigFlag(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("format = json\nlimit = 20\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	gflags := flag.NewFlagSet("global", flag.ContinueOnError)
+	gflags.String("config", "", "config file")
+
+	list := newTestCmd("list")
+	format := list.flags.String("format", "text", "output format")
+	limit := list.flags.Int("limit", 10, "maximum number of items")
+
+	opts := &Options{GlobalFlags: gflags, ConfigFlag: "config"}
+	if err := opts.Run(ctx, []Command{list}, []string{"-config", path, "list", "-limit=5"}); err != nil {
+		t.Fatal(err)
+	}
+	if *format != "json" {
+		t.Fatalf("want format from the config, got %q", *format)
+	}
+	if *limit != 5 {
+		t.Fatalf("want limit from the command-line, got %d", *limit)
+	}
+}
+
+func TestConfigRequiredFlag(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"name": "alice"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	gflags := flag.NewFlagSet("global", flag.ContinueOnError)
+	gflags.String("config", "", "config file")
+
+	greet := newTestCmd("greet")
+	name := greet.flags.String("name", "", "name to greet")
+	if err := MarkFlagRequired(greet.flags, "name"); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{GlobalFlags: gflags, ConfigFlag: "config"}
+	if err := opts.Run(ctx, []Command{greet}, []string{"-config", path, "greet"}); err != nil {
+		t.Fatal(err)
+	}
+	if *name != "alice" {
+		t.Fatalf("want name from the config, got %q", *name)
+	}
+
+	if _, _, _, err := opts.Resolve([]Command{greet}, []string{"greet"}); err != nil {
+		t.Fatalf("want no required flag checks in Resolve, got %v", err)
+	}
+	if err := opts.Run(ctx, []Command{greet}, []string{"-config=", "greet"}); !errors.Is(err, ErrFlagConstraint) {
+		t.Fatalf("want ErrFlagConstraint without the config, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+)
+
+type cmdPathKey struct{}
+
+type stdinKey struct{}
+
+type quietKey struct{}
+
+// CommandPath returns the resolved command path, excluding the program name,
+// for the running command. Returns nil if the context is not derived from a
+// context passed to a command by this package.
+//
+// Example:
+//
+//	func pauseJob(ctx context.Context, args []string) error {
+//	    log.Printf("running %s", strings.Join(cli.CommandPath(ctx), " "))
+//	    return nil
+//	}
+func CommandPath(ctx context.Context) []string {
+	if v, ok := ctx.Value(cmdPathKey{}).([]string); ok {
+		return slices.Clone(v)
+	}
+	return nil
+}
+
+// Stdin returns the standard input for the running command, which is the
+// [Options.Stdin] reader, if set, or os.Stdin otherwise. Commands can use it
+// instead of os.Stdin to allow injecting the input in tests.
+//
+// Example:
+//
+//	func countLines(ctx context.Context, args []string) error {
+//	    scanner := bufio.NewScanner(cli.Stdin(ctx))
+//	    ...
+//	}
+func Stdin(ctx context.Context) io.Reader {
+	if r, ok := ctx.Value(stdinKey{}).(io.Reader); ok {
+		return r
+	}
+	return os.Stdin
+}
+
+// Quiet reports true if the -quiet flag is set for the running command; see
+// [Options.QuietFlag].
+//
+// Example:
+//
+//	func sync(ctx context.Context, args []string) error {
+//	    if !cli.Quiet(ctx) {
+//	        log.Printf("syncing %d files", len(args))
+//	    }
+//	    ...
+//	}
+func Quiet(ctx context.Context) bool {
+	v, _ := ctx.Value(quietKey{}).(bool)
+	return v
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommandPath(t *testing.T) {
+	ctx := context.Background()
+
+	var path []string
+	pause := NewCommand("pause", func(ctx context.Context, args []string) error {
+		path = CommandPath(ctx)
+		return nil
+	}, nil, "pause job")
+	job := NewGroup("job", "manage single job", pause)
+
+	if err := Run(ctx, []Command{job}, []string{"job", "pause", "arg"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"job", "pause"}; !slices.Equal(path, want) {
+		t.Fatalf("want %v, got %v", want, path)
+	}
+	if CommandPath(ctx) != nil {
+		t.Fatalf("want nil command path outside of a command")
+	}
+}
+
+func TestStdin(t *testing.T) {
+	ctx := context.Background()
+
+	var input string
+	cat := NewCommand("cat", func(ctx context.Context, args []string) error {
+		data, err := io.ReadAll(Stdin(ctx))
+		input = string(data)
+		return err
+	}, nil, "print input")
+
+	opts := &Options{Stdin: strings.NewReader("hello\n")}
+	if err := opts.Run(ctx, []Command{cat}, []string{"cat"}); err != nil {
+		t.Fatal(err)
+	}
+	if input != "hello\n" {
+		t.Fatalf("want %q, got %q", "hello\n", input)
+	}
+	if Stdin(ctx) != os.Stdin {
+		t.Fatalf("want os.Stdin by default")
+	}
+}
+
+func TestContextDecorator(t *testing.T) {
+	ctx := context.Background()
+
+	type loggerKey struct{}
+	var logger any
+	pause := NewCommand("pause", func(ctx context.Context, args []string) error {
+		logger = ctx.Value(loggerKey{})
+		return nil
+	}, nil, "pause job")
+	job := NewGroup("job", "manage single job", pause)
+
+	var decorated []string
+	opts := &Options{
+		ContextDecorator: func(ctx context.Context) context.Context {
+			decorated = CommandPath(ctx)
+			return context.WithValue(ctx, loggerKey{}, "job-logger")
+		},
+	}
+	if err := opts.Run(ctx, []Command{job}, []string{"job", "pause"}); err != nil {
+		t.Fatal(err)
+	}
+	if logger != "job-logger" {
+		t.Fatalf("want the injected value, got %v", logger)
+	}
+	if want := []string{"job", "pause"}; !slices.Equal(decorated, want) {
+		t.Fatalf("want command path %v in the decorator, got %v", want, decorated)
+	}
+}
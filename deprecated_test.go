@@ -0,0 +1,178 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+type deprecatedCmd struct {
+	*TestCmd
+}
+
+func (d *deprecatedCmd) Deprecated() string {
+	return "use 'get'"
+}
+
+type removedCmd struct {
+	*TestCmd
+}
+
+func (r *removedCmd) Deprecated() string {
+	return "use 'get'"
+}
+
+func (r *removedCmd) RemoveIn() string {
+	return "v2.0"
+}
+
+func TestDeprecation(t *testing.T) {
+	ctx := context.Background()
+
+	get := newTestCmd("get")
+	get.flags.String("addr", "", "server address")
+	if err := MarkFlagDeprecated(get.flags, "addr", "use -host instead"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkFlagDeprecated(get.flags, "undefined", "message"); err == nil {
+		t.Fatalf("want error for undefined flag")
+	}
+	fetch := &deprecatedCmd{newTestCmd("fetch")}
+	cmds := []Command{get, fetch}
+
+	var stderr strings.Builder
+	opts := &Options{Stderr: &stderr}
+	if err := opts.Run(ctx, cmds, []string{"fetch", "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "command 'fetch' is deprecated: use 'get'\n"; stderr.String() != want {
+		t.Fatalf("want %q, got %q", want, stderr.String())
+	}
+
+	stderr.Reset()
+	if err := opts.Run(ctx, cmds, []string{"get", "-addr", "x", "-addr=y", "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "flag -addr is deprecated: use -host instead\n"; stderr.String() != want {
+		t.Fatalf("want %q, got %q", want, stderr.String())
+	}
+
+	var stdout strings.Builder
+	opts = &Options{Stdout: &stdout}
+	if err := opts.Run(ctx, cmds, []string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "fetch            (deprecated)") {
+		t.Fatalf("want deprecated note in help, got %q", stdout.String())
+	}
+}
+
+func TestDeprecatedImplicitCommand(t *testing.T) {
+	ctx := context.Background()
+
+	fetch := &deprecatedCmd{newTestCmd("fetch")}
+	cmds := []Command{newTestCmd("get"), fetch}
+	t.Setenv("APP_COMMAND", "fetch")
+
+	var stderr strings.Builder
+	want := "command 'fetch' is deprecated: use 'get'\n"
+	for _, opts := range []*Options{
+		{Stderr: &stderr, DefaultCommand: "fetch"},
+		{Stderr: &stderr, CommandFromEnv: "APP_COMMAND"},
+	} {
+		stderr.Reset()
+		if err := opts.Run(ctx, cmds, nil); err != nil {
+			t.Fatal(err)
+		}
+		if stderr.String() != want {
+			t.Errorf("want %q, got %q", want, stderr.String())
+		}
+	}
+}
+
+func TestDeprecationRemoveIn(t *testing.T) {
+	ctx := context.Background()
+
+	get := newTestCmd("get")
+	get.flags.String("addr", "", "server address")
+	if err := MarkFlagDeprecated(get.flags, "addr", "use -host instead"); err != nil {
+		t.Fatal(err)
+	}
+	lookup := &removedCmd{newTestCmd("lookup")}
+	cmds := []Command{get, lookup}
+
+	var stderr strings.Builder
+	opts := &Options{Stderr: &stderr, NoDeprecationWarningsEnv: "TEST_NO_DEPRECATION_WARNINGS"}
+	if err := opts.Run(ctx, cmds, []string{"lookup", "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "command 'lookup' is deprecated and will be removed in v2.0: use 'get'\n"; stderr.String() != want {
+		t.Fatalf("want %q, got %q", want, stderr.String())
+	}
+
+	t.Setenv("TEST_NO_DEPRECATION_WARNINGS", "1")
+	stderr.Reset()
+	if err := opts.Run(ctx, cmds, []string{"lookup", "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.Run(ctx, cmds, []string{"get", "-addr=x", "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("want no warnings, got %q", stderr.String())
+	}
+}
+
+func TestQuietFlag(t *testing.T) {
+	ctx := context.Background()
+
+	var quiet bool
+	fetch := &deprecatedCmd{newTestCmd("fetch")}
+	status := NewCommand("status", func(ctx context.Context, args []string) error {
+		quiet = Quiet(ctx)
+		return nil
+	}, nil, "print the status")
+	cmds := []Command{fetch, status}
+
+	var stderr strings.Builder
+	opts := &Options{Stderr: &stderr, QuietFlag: true}
+	for _, args := range [][]string{{"fetch", "-q"}, {"--quiet", "fetch", "key"}} {
+		if err := opts.Run(ctx, cmds, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("want no warnings, got %q", stderr.String())
+	}
+	if err := opts.Run(ctx, cmds, []string{"fetch"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "command 'fetch' is deprecated: use 'get'\n"; stderr.String() != want {
+		t.Fatalf("want %q, got %q", want, stderr.String())
+	}
+
+	if err := opts.Run(ctx, cmds, []string{"status", "-q"}); err != nil {
+		t.Fatal(err)
+	}
+	if !quiet {
+		t.Fatalf("want quiet in the command context")
+	}
+	if err := opts.Run(ctx, cmds, []string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	if quiet {
+		t.Fatalf("want not quiet without the flag")
+	}
+
+	gflags := flag.NewFlagSet("global", flag.ContinueOnError)
+	gflags.Bool("q", false, "query mode")
+	opts = &Options{GlobalFlags: gflags, QuietFlag: true}
+	if err := opts.Run(ctx, cmds, []string{"status"}); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("want os.ErrExist for the conflicting global flag, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Dispatcher runs the commands from a command tree that is validated and
+// built only once, which is useful for running many command-lines in the same
+// process, as in a REPL. Flags are restored to their default values before
+// each run, same as with the [Options.ResetFlagValues]. Runs are serialized,
+// because the flag values are shared by all runs.
+type Dispatcher struct {
+	mu   sync.Mutex
+	root *groupCmd
+}
+
+// NewDispatcher validates the commands and returns a Dispatcher that runs them
+// as per the options, which may be nil. Options must not be modified after
+// this call. Returns an error if a command is invalid or if a command name is
+// used more than once in the same group.
+//
+// Example:
+//
+//	d, err := cli.NewDispatcher(nil, cmds)
+//	if err != nil {
+//	    return err
+//	}
+//	for scanner.Scan() {
+//	    if err := d.Run(ctx, strings.Fields(scanner.Text())); err != nil {
+//	        log.Print(err)
+//	    }
+//	}
+func NewDispatcher(opts *Options, cmds []Command) (*Dispatcher, error) {
+	o := new(Options)
+	if opts != nil {
+		*o = *opts
+	}
+	o.ResetFlagValues = true
+
+	root, err := o.newRoot(cmds)
+	if err != nil {
+		return nil, err
+	}
+	return &Dispatcher{root: root}, nil
+}
+
+// checkUnique returns an error if a command name is defined more than once in
+// the same group. Lazy commands are not constructed.
+func (opts *Options) checkUnique(path []string, cmds []Command) error {
+	names := make(map[string]bool)
+	for _, c := range cmds {
+		if c == nil {
+			return fmt.Errorf("command cannot be nil: %w", os.ErrInvalid)
+		}
+		name := cmdName(c)
+		if key := opts.cmdKey(name); names[key] {
+			return fmt.Errorf("command %q is defined more than once: %w", append(path, name), os.ErrExist)
+		} else {
+			names[key] = true
+		}
+		if gc, ok := c.(*groupCmd); ok {
+			if err := opts.checkUnique(append(path, name), gc.subcmds); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run runs the command selected by the arguments, same as [Options.Run].
+func (d *Dispatcher) Run(ctx context.Context, args []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.root.resetState()
+	if d.root.opts.HandleSignals {
+		sctx, stop := withSignals(ctx)
+		defer stop()
+		ctx = sctx
+	}
+	return d.root.run(ctx, trimArgs(args))
+}
+
+// Resolve parses the arguments without executing any command, same as
+// [Options.Resolve]. Wrappers can use the special result, which holds the
+// default name of the selected built-in command, if any, to tell the
+// informational runs, like "help" or "-h", apart from the command executions
+// before calling Run with the same arguments.
+func (d *Dispatcher) Resolve(args []string) (path []string, remaining []string, special string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.root.resetState()
+	cmdpath, remaining, err := d.root.resolve(context.Background(), trimArgs(args))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return cmdNames(cmdpath), remaining, d.root.specialCmd, nil
+}
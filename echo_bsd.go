@@ -0,0 +1,13 @@
+// Copyright (c) 2025 Visvasity LLC
+
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package cli
+
+import "syscall"
+
+// ioctl requests that get and set the terminal attributes.
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
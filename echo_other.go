@@ -0,0 +1,15 @@
+// Copyright (c) 2025 Visvasity LLC
+
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package cli
+
+// canDisableEcho reports true if disableEcho is supported on this platform.
+// Secret flags are not prompted for, because the input would be echoed.
+const canDisableEcho = false
+
+// disableEcho is not supported on this platform, so the terminal input is
+// always echoed.
+func disableEcho(v any) (restore func()) {
+	return func() {}
+}
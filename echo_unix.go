@@ -0,0 +1,35 @@
+// Copyright (c) 2025 Visvasity LLC
+
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package cli
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// canDisableEcho reports true if disableEcho is supported on this platform.
+const canDisableEcho = true
+
+// disableEcho turns off the echo for the terminal input and returns a function
+// that restores it. Inputs that are not terminals are left unchanged.
+func disableEcho(v any) (restore func()) {
+	f, ok := v.(*os.File)
+	if !ok {
+		return func() {}
+	}
+	var saved syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlGetTermios, uintptr(unsafe.Pointer(&saved))); errno != 0 {
+		return func() {}
+	}
+	t := saved
+	t.Lflag &^= syscall.ECHO
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlSetTermios, uintptr(unsafe.Pointer(&t))); errno != 0 {
+		return func() {}
+	}
+	return func() {
+		syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlSetTermios, uintptr(unsafe.Pointer(&saved)))
+	}
+}
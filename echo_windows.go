@@ -0,0 +1,38 @@
+// Copyright (c) 2025 Visvasity LLC
+
+//go:build windows
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+// canDisableEcho reports true if disableEcho is supported on this platform.
+const canDisableEcho = true
+
+// enableEchoInput is the console mode flag that echoes the input.
+const enableEchoInput = 0x0004
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// disableEcho turns off the echo for the console input and returns a function
+// that restores it. Inputs that are not consoles are left unchanged.
+func disableEcho(v any) (restore func()) {
+	f, ok := v.(*os.File)
+	if !ok {
+		return func() {}
+	}
+	h := syscall.Handle(f.Fd())
+	var saved uint32
+	if err := syscall.GetConsoleMode(h, &saved); err != nil {
+		return func() {}
+	}
+	if r, _, _ := procSetConsoleMode.Call(uintptr(h), uintptr(saved&^enableEchoInput)); r == 0 {
+		return func() {}
+	}
+	return func() {
+		procSetConsoleMode.Call(uintptr(h), uintptr(saved))
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by [Run] for the command-line parsing failures. Returned
+// errors wrap these values, so callers can check for them with [errors.Is].
+var (
+	ErrUnknownCommand      = errors.New("command not defined")
+	ErrCommandNotAvailable = errors.New("command not available")
+	ErrAmbiguousCommand    = errors.New("ambiguous command")
+	ErrUnknownFlag         = errors.New("flag provided but not defined")
+	ErrAmbiguousFlag       = errors.New("ambiguous flag")
+	ErrBadFlagSyntax       = errors.New("bad flag syntax")
+	ErrFlagNeedsArg        = errors.New("flag needs an argument")
+	ErrInvalidFlagValue    = errors.New("invalid flag value")
+	ErrFlagConstraint      = errors.New("flag constraint violated")
+	ErrShadowedFlag        = errors.New("flag is shadowed")
+	ErrInvalidArgs         = errors.New("invalid arguments")
+)
+
+// ParseError is the error type for the command-line parsing failures, which
+// wrap one of the errors above. Its Usage method returns the help text for
+// the command where the parsing failed.
+//
+// Example:
+//
+//	var pe *cli.ParseError
+//	if err := cli.Run(ctx, cmds, os.Args); errors.As(err, &pe) {
+//	    fmt.Fprintln(os.Stderr, err)
+//	    fmt.Fprint(os.Stderr, pe.Usage())
+//	}
+type ParseError struct {
+	kind error
+	err  error
+
+	// opts and cmdpath, when non-nil, select the command where the parsing
+	// failed, so that its help text is rendered on demand.
+	opts    *Options
+	cmdpath []*cmdData
+}
+
+// newParseError returns a parsing error of the given kind with a formatted
+// message. Format string may use %w verbs to wrap other errors.
+func newParseError(kind error, format string, args ...any) error {
+	return &ParseError{kind: kind, err: fmt.Errorf(format, args...)}
+}
+
+func (e *ParseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ParseError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// Usage returns the help text for the command where the parsing failed, which
+// may be empty.
+func (e *ParseError) Usage() string {
+	if e.opts == nil {
+		return ""
+	}
+	var sb strings.Builder
+	printHelp(&sb, e.opts, e.cmdpath)
+	return sb.String()
+}
+
+// IsUsageError reports true if the error is a command-line parsing error
+// returned by this package, like [ErrUnknownFlag] or [ErrInvalidArgs], as
+// opposed to an error returned by a command. It can be used as the
+// [Options.ShowUsageOnError] predicate.
+func IsUsageError(err error) bool {
+	pe := new(ParseError)
+	return errors.As(err, &pe)
+}
+
+// withCommandPath prefixes the parsing error message with the command path, as
+// in "server start: invalid value...", so that the errors from the deeply
+// nested commands are easy to locate. Errors for the top-level are returned
+// as is.
+func withCommandPath(path []string, err error) error {
+	var pe *ParseError
+	if len(path) == 0 || !errors.As(err, &pe) {
+		return err
+	}
+	return &ParseError{kind: pe.kind, err: fmt.Errorf("%s: %w", strings.Join(path, " "), pe.err)}
+}
+
+// ErrorUsage returns the help text for the command where the command-line
+// parsing failed, if the error is a parsing error returned by [Run], so that
+// callers can print the error along with the relevant usage without resolving
+// the command-line again, same as [ParseError.Usage]. Returns an empty string
+// for the other errors.
+//
+// Example:
+//
+//	if err := cli.Run(ctx, cmds, os.Args); err != nil {
+//	    fmt.Fprintln(os.Stderr, err)
+//	    fmt.Fprint(os.Stderr, cli.ErrorUsage(err))
+//	}
+func ErrorUsage(err error) string {
+	if pe := new(ParseError); errors.As(err, &pe) {
+		return pe.Usage()
+	}
+	return ""
+}
+
+// FormatError returns the error message prefixed with the command path, as in
+// "myapp db scan: connection refused", so that the errors are presented the
+// same way for all commands. Parts of the path that already prefix the
+// message, as for the parsing errors, are not repeated. Messages of the
+// wrapped errors that are not included in the message, as for the error types
+// that do not print their causes, are appended on separate lines.
+//
+// Example:
+//
+//	if err := cli.Run(ctx, cmds, os.Args); err != nil {
+//	    fmt.Fprintln(os.Stderr, cli.FormatError(err, []string{"myapp"}))
+//	}
+func FormatError(err error, path []string) string {
+	if err == nil {
+		return ""
+	}
+	msg := err.Error()
+	lines := []string{msg}
+	for e := errors.Unwrap(err); e != nil; e = errors.Unwrap(e) {
+		if cause := e.Error(); !strings.Contains(msg, cause) {
+			lines = append(lines, "caused by: "+cause)
+			msg = cause
+		}
+	}
+	sep := ": "
+	for i := range path {
+		if strings.HasPrefix(lines[0], strings.Join(path[i:], " ")+": ") {
+			path, sep = path[:i], " "
+			break
+		}
+	}
+	if len(path) > 0 {
+		lines[0] = strings.Join(path, " ") + sep + lines[0]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	ctx := context.Background()
+
+	list := newTestCmd("list")
+	list.flags.Int("limit", 10, "maximum number of items")
+	list.flags.Bool("all", false, "list all items")
+	var verbosity int
+	CountVar(list.flags, &verbosity, "v", "increase verbosity")
+	jobs := NewGroup("jobs", "manage jobs", list)
+	cmds := []Command{jobs}
+
+	tests := []struct {
+		args    []string
+		wantErr error
+		wantMsg string
+	}{
+		{[]string{"jobs", "restart"}, ErrUnknownCommand, "command not defined: restart (available: list)"},
+		{[]string{"jobs", "--", "restart"}, ErrUnknownCommand, `no command before "--" for command group "jobs" (available: list)`},
+		{[]string{"jobs", "--"}, ErrUnknownCommand, `no command before "--" for command group "jobs" (available: list)`},
+		{[]string{"jobs", "list", "-xyz"}, ErrUnknownFlag, "jobs list: flag provided but not defined: -xyz"},
+		{[]string{"jobs", "list", "---limit"}, ErrBadFlagSyntax, "jobs list: bad flag syntax: ---limit"},
+		{[]string{"jobs", "list", "-limit"}, ErrFlagNeedsArg, "jobs list: flag needs an argument: -limit"},
+		{[]string{"jobs", "list", "-limit=x"}, ErrInvalidFlagValue, `jobs list: invalid value "x" for flag -limit: parse error`},
+		{[]string{"jobs", "list", "-all=x"}, ErrInvalidFlagValue, `jobs list: invalid boolean value "x" for -all: parse error`},
+	}
+	for _, tt := range tests {
+		err := Run(ctx, cmds, tt.args)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Run(%v): got error %v, want %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err.Error() != tt.wantMsg {
+			t.Errorf("Run(%v): got message %q, want %q", tt.args, err.Error(), tt.wantMsg)
+		}
+	}
+
+	err := Run(ctx, cmds, []string{"jobs", "list", "-v=x"})
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("want wrapped strconv.ErrSyntax, got %v", err)
+	}
+}
+
+func TestErrorUsage(t *testing.T) {
+	ctx := context.Background()
+
+	list := &noArgsCmd{newTestCmd("list")}
+	list.flags.Int("limit", 10, "maximum number of items")
+	jobs := NewGroup("jobs", "manage jobs", list, newTestCmd("pause"))
+	cmds := []Command{jobs}
+	opts := &Options{IsolateCommandLine: true}
+
+	tests := []struct {
+		args      []string
+		wantUsage string
+	}{
+		{[]string{"jobs", "restart"}, "Usage: cli.test jobs <subcommand> <args>\n"},
+		{[]string{"jobs", "list", "-xyz"}, "Usage: cli.test jobs list <flags> <args>\n"},
+		{[]string{"jobs", "list", "extra"}, "Usage: cli.test jobs list <flags> <args>\n"},
+	}
+	for _, tt := range tests {
+		err := opts.Run(ctx, cmds, tt.args)
+		if err == nil {
+			t.Errorf("Run(%q): want error", tt.args)
+			continue
+		}
+		if usage := ErrorUsage(err); !strings.HasPrefix(usage, tt.wantUsage) {
+			t.Errorf("Run(%q): got usage %q, want prefix %q", tt.args, usage, tt.wantUsage)
+		}
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.Usage() != ErrorUsage(err) {
+			t.Errorf("Run(%q): want a ParseError with the same usage, got %#v", tt.args, err)
+		}
+	}
+
+	// usage is also rendered on demand for the Resolve errors
+	_, _, _, err := opts.Resolve(cmds, []string{"jobs", "list", "-xyz"})
+	if usage := ErrorUsage(err); !strings.HasPrefix(usage, "Usage: cli.test jobs list <flags> <args>\n") {
+		t.Errorf("Resolve: got usage %q", usage)
+	}
+
+	if usage := ErrorUsage(errors.New("failed")); usage != "" {
+		t.Errorf("want empty usage for other errors, got %q", usage)
+	}
+}
+
+func TestUnknownCommandAvailable(t *testing.T) {
+	ctx := context.Background()
+
+	cmds := []Command{newTestCmd("run"), newTestCmd("version")}
+	err := Run(ctx, cmds, []string{"restart"})
+	if want := "command not defined: restart (available: run, version, help, flags, commands)"; err == nil || err.Error() != want {
+		t.Fatalf("want %q, got %v", want, err)
+	}
+}
+
+func TestShadowedFlags(t *testing.T) {
+	ctx := context.Background()
+
+	start := newTestCmd("start")
+	start.flags.Int("port", 8080, "server port")
+	sflags := flag.NewFlagSet("server", flag.ContinueOnError)
+	sflags.Int("port", 80, "default server port")
+	server := NewGroupWithFlags("server", "manage server", sflags, start)
+	cmds := []Command{server}
+
+	if err := Run(ctx, cmds, []string{"server", "start", "-port", "9090"}); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{RejectShadowedFlags: true}
+	err := opts.Run(ctx, cmds, []string{"server", "start", "-port", "9090"})
+	if !errors.Is(err, ErrShadowedFlag) {
+		t.Fatalf("want ErrShadowedFlag, got %v", err)
+	}
+	if want := `flag -port is defined by multiple commands: "server", "server start"`; err.Error() != want {
+		t.Fatalf("got message %q, want %q", err.Error(), want)
+	}
+}
+
+func TestFlagErrorHandling(t *testing.T) {
+	ctx := context.Background()
+
+	fset := flag.NewFlagSet("", flag.PanicOnError)
+	fset.Int("limit", 10, "maximum number of items")
+	list := NewCommand("list", func(context.Context, []string) error { return nil }, fset, "list items")
+	if fset.ErrorHandling() != flag.PanicOnError {
+		t.Fatalf("want error handling to be preserved")
+	}
+
+	// help is still available for invalid flags
+	if _, _, err := RunCapture(ctx, []Command{list}, []string{"list", "-limit=x", "-h"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// errors are returned by default
+	if err := Run(ctx, []Command{list}, []string{"list", "-limit=x"}); !errors.Is(err, ErrInvalidFlagValue) {
+		t.Fatalf("want ErrInvalidFlagValue, got %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("want panic for flag.PanicOnError")
+		} else if err, ok := r.(error); !ok || !errors.Is(err, ErrInvalidFlagValue) {
+			t.Fatalf("want ErrInvalidFlagValue panic, got %v", r)
+		}
+	}()
+	opts := &Options{FlagErrorHandling: true}
+	opts.Run(ctx, []Command{list}, []string{"list", "-limit=x"})
+}
+
+func TestErrorFormat(t *testing.T) {
+	ctx := context.Background()
+
+	list := NewCommand("list", func(context.Context, []string) error {
+		return &ExitError{Code: 3, Err: errors.New("database is locked")}
+	}, nil, "list items")
+
+	err := Run(ctx, []Command{list}, []string{"list"})
+	if got := exitCode(err); got != 3 {
+		t.Fatalf("want exit code 3, got %d", got)
+	}
+	if got := exitCode(Run(ctx, []Command{list}, []string{"list", "-x"})); got != 2 {
+		t.Fatalf("want exit code 2 for parse errors, got %d", got)
+	}
+	if got := exitCode(errors.New("failed")); got != 1 {
+		t.Fatalf("want exit code 1, got %d", got)
+	}
+
+	var sb strings.Builder
+	opts := &Options{ErrorFormat: ErrorFormatJSON}
+	opts.printError(&sb, err, nil)
+	if want := `{"error":"database is locked","code":3}` + "\n"; sb.String() != want {
+		t.Fatalf("got %q, want %q", sb.String(), want)
+	}
+
+	sb.Reset()
+	new(Options).printError(&sb, err, nil)
+	if want := "database is locked\n"; sb.String() != want {
+		t.Fatalf("got %q, want %q", sb.String(), want)
+	}
+}
+
+// opError is an error type that does not print its cause.
+type opError struct {
+	op  string
+	err error
+}
+
+func (e *opError) Error() string { return e.op + " failed" }
+
+func (e *opError) Unwrap() error { return e.err }
+
+func TestFormatError(t *testing.T) {
+	ctx := context.Background()
+
+	refused := errors.New("connection refused")
+	scan := NewCommand("scan", func(context.Context, []string) error {
+		return fmt.Errorf("could not connect: %w", refused)
+	}, nil, "scan the keys")
+	db := NewGroup("db", "manage database", scan)
+
+	root, err := new(Options).runRoot(ctx, []Command{db}, []string{"db", "scan"})
+	if err == nil {
+		t.Fatal("want error")
+	}
+	path := append([]string{"myapp"}, root.path...)
+	if got, want := FormatError(err, path), "myapp db scan: could not connect: connection refused"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	root, err = new(Options).runRoot(ctx, []Command{db}, []string{"db", "scan", "-x"})
+	path = append([]string{"myapp"}, root.path...)
+	if got, want := FormatError(err, path), "myapp db scan: flag provided but not defined: -x"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	err = &opError{op: "backup", err: fmt.Errorf("write: %w", refused)}
+	if got, want := FormatError(err, []string{"myapp", "db"}), "myapp db: backup failed\ncaused by: write: connection refused"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := FormatError(nil, []string{"myapp"}); got != "" {
+		t.Fatalf("want empty string for nil error, got %q", got)
+	}
+}
@@ -68,11 +68,15 @@ func (c *List) Purpose() string {
 	return "Prints keys and values in the database"
 }
 
-func (c *List) Run(ctx context.Context, args []string) error {
-	if len(args) != 0 {
-		return fmt.Errorf("command takes no arguments")
-	}
+func (c *List) ArgNames() []string {
+	return nil
+}
 
+func (c *List) ValidateArgs(args []string) error {
+	return cli.NoArgs(args)
+}
+
+func (c *List) Run(ctx context.Context, args []string) error {
 	js, _ := json.MarshalIndent(&c, "", "  ")
 	fmt.Printf("%s\n", js)
 	return nil
@@ -80,7 +84,7 @@ func (c *List) Run(ctx context.Context, args []string) error {
 
 func main() {
 	cmds := []cli.Command{
-		new(List),
+		cli.AdaptCommand(new(List)),
 	}
 	if err := cli.Run(context.Background(), cmds, os.Args); err != nil {
 		log.Fatal(err)
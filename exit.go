@@ -0,0 +1,107 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrorFormat selects how [Options.Main] prints the errors.
+type ErrorFormat int
+
+const (
+	// ErrorFormatText prints the error message as a single line of text.
+	ErrorFormatText ErrorFormat = iota
+
+	// ErrorFormatJSON prints the error as a JSON object with the "error" and
+	// "code" fields, as in {"error":"...","code":1}.
+	ErrorFormatJSON
+)
+
+// ExitError is an error with a process exit code. Commands can return an
+// ExitError to choose the exit status for [Main].
+//
+// Example:
+//
+//	if !healthy {
+//	    return &cli.ExitError{Code: 3, Err: errors.New("service is unhealthy")}
+//	}
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// exitCode returns the process exit code for an error. Command-line parsing
+// errors exit with status 2, same as the flag package, and the other errors
+// exit with status 1, unless they wrap an [ExitError].
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if ee := new(ExitError); errors.As(err, &ee) {
+		return ee.Code
+	}
+	if IsUsageError(err) {
+		return 2
+	}
+	return 1
+}
+
+// printError prints the error to the writer in the configured format, with
+// the command path prefix; see [FormatError].
+func (opts *Options) printError(w io.Writer, err error, path []string) {
+	if opts.ErrorFormat == ErrorFormatJSON {
+		json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+			Code  int    `json:"code"`
+		}{FormatError(err, path), exitCode(err)})
+		return
+	}
+	fmt.Fprintln(w, FormatError(err, path))
+}
+
+// Main runs the CLI with the os.Args, similar to [Options.Run], and exits the
+// process with a non-zero status when it fails, after printing the error to
+// Stderr in the configured format. Errors are prefixed with the program name
+// and the resolved command path, as in "myapp db scan: connection refused";
+// see [FormatError]. Main returns normally when the command succeeds.
+//
+// Example:
+//
+//	func main() {
+//	    opts := &cli.Options{ErrorFormat: cli.ErrorFormatJSON}
+//	    opts.Main(context.Background(), cmds)
+//	}
+func (opts *Options) Main(ctx context.Context, cmds []Command) {
+	root, err := opts.runRoot(ctx, cmds, os.Args)
+	if err != nil {
+		var path []string
+		if root != nil {
+			path = append([]string{filepath.Base(root.flags.Name())}, root.path...)
+		}
+		opts.printError(opts.stderr(), err, path)
+		os.Exit(exitCode(err))
+	}
+}
+
+// Main is similar to [Options.Main], but uses the default options.
+func Main(ctx context.Context, cmds []Command) {
+	new(Options).Main(ctx, cmds)
+}
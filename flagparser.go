@@ -0,0 +1,122 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// FlagParser decodes the flag arguments from the command-line, so that the
+// flag syntax can be customized without changing how the commands are
+// resolved. The package selects the command-line arguments that are flags,
+// handles the "--" terminator, and sets the flag values from the results.
+//
+// By default, a built-in parser that follows the GNUFlags, CombinedShortFlags
+// and GetoptLong options is used; see [Options.FlagParser].
+type FlagParser interface {
+	// ParseFlag decodes a flag argument, which starts with a '-' and is not
+	// "--", into one or more flags, as "-a" and "-b" for "-ab". The lookup
+	// function reports if a flag name is defined and if it is a boolean flag.
+	// Flags without an attached value take the next argument as their value,
+	// unless they are boolean flags, so only the last flag can do that. Errors
+	// should wrap [ErrBadFlagSyntax] or [ErrUnknownFlag].
+	ParseFlag(arg string, lookup func(name string) (defined, isBool bool)) ([]ParsedFlag, error)
+}
+
+// ParsedFlag is a flag decoded by a [FlagParser].
+type ParsedFlag struct {
+	// Name is the flag name without the dash prefix.
+	Name string
+
+	// Value is the value attached to the flag, as in "--name=value" or
+	// "-fvalue", when HasValue is true.
+	Value    string
+	HasValue bool
+}
+
+// flagParser returns the parser for the flag arguments.
+func (opts *Options) flagParser() FlagParser {
+	if opts.FlagParser != nil {
+		return opts.FlagParser
+	}
+	return &builtinFlagParser{opts: opts}
+}
+
+// builtinFlagParser is the default FlagParser, which accepts the flags with
+// one or two dashes and the "=value" suffix, as the flag package does, along
+// with the forms enabled by the options.
+type builtinFlagParser struct {
+	opts *Options
+}
+
+func (p *builtinFlagParser) ParseFlag(arg string, lookup func(string) (bool, bool)) ([]ParsedFlag, error) {
+	// remove the '-' or '--' prefix and '=...' suffix
+	name := arg[1:]
+	if arg[1] == '-' {
+		name = arg[2:]
+	}
+	if len(name) == 0 || name[0] == '-' || name[0] == '=' {
+		return nil, newParseError(ErrBadFlagSyntax, "bad flag syntax: %s", arg)
+	}
+	// value is everything after the first '=', which may be empty or contain
+	// more '=' characters.
+	name, value, hasValue := strings.Cut(name, "=")
+
+	defined, _ := lookup(name)
+	short := arg[1] != '-'
+	combined, attached := name, p.opts.GetoptLong && short && hasValue
+	if attached {
+		// the value attached to a short flag, as in "-fa=b", may contain '='
+		combined = arg[1:]
+	}
+	if (!defined || p.opts.GNUFlags) && p.opts.CombinedShortFlags && short && len(combined) > 1 && (!hasValue || attached) {
+		split, err := splitShortFlags(lookup, combined, p.opts.GetoptLong)
+		if err != nil {
+			return nil, err
+		}
+		if len(split) > 0 {
+			return split, nil
+		}
+	}
+	if p.opts.GNUFlags {
+		if short && len(name) > 1 {
+			return nil, newParseError(ErrBadFlagSyntax, "bad flag syntax: %s; use --%s", arg, name)
+		}
+		if !short && len(name) == 1 {
+			return nil, newParseError(ErrBadFlagSyntax, "bad flag syntax: %s; use -%s", arg, name)
+		}
+	}
+	return []ParsedFlag{{Name: name, Value: value, HasValue: hasValue}}, nil
+}
+
+// splitShortFlags returns the individual flags for a combined short flag, as
+// "-a", "-b" and "-c" for "-abc". Returns nil if any of the characters is not
+// a defined flag, so that the argument is reported as an unknown flag. When
+// values are attached, the first non-boolean flag takes the rest of the
+// argument as its value, as "-a" and "-f" with "value" for "-afvalue", and a
+// value attached to the boolean flags, as in "-v=true", is an error, same as
+// getopt.
+func splitShortFlags(lookup func(string) (bool, bool), name string, attached bool) ([]ParsedFlag, error) {
+	var flags []ParsedFlag
+	for i, c := range name {
+		defined, isBool := lookup(string(c))
+		if !defined {
+			if attached && c == '=' && i > 0 {
+				prev, _ := utf8.DecodeLastRuneInString(name[:i])
+				return nil, newParseError(ErrBadFlagSyntax, "bad flag syntax: -%s; boolean flag -%c does not take a value", name, prev)
+			}
+			return nil, nil
+		}
+		if !isBool {
+			if !attached {
+				return nil, newParseError(ErrBadFlagSyntax, "flag -%c in -%s is not a boolean flag", c, name)
+			}
+			// value is the next argument when nothing is attached
+			rest := name[i+utf8.RuneLen(c):]
+			return append(flags, ParsedFlag{Name: string(c), Value: rest, HasValue: len(rest) > 0}), nil
+		}
+		flags = append(flags, ParsedFlag{Name: string(c)})
+	}
+	return flags, nil
+}
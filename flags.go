@@ -0,0 +1,625 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flagMeta holds the additional information about a flag that is attached
+// through the package helper functions.
+type flagMeta struct {
+	deprecated string
+
+	// timeout is true for the flags defined by TimeoutVar.
+	timeout bool
+
+	// category is the flag category for the help output.
+	category string
+
+	// validator, when non-nil, checks the flag values from the command-line.
+	validator func(value string) error
+
+	// aliasOf is the canonical flag name for the alias flags.
+	aliasOf string
+
+	// aliases holds the alias names for the canonical flags.
+	aliases []string
+
+	// required is true for the flags that must be set in the command-line.
+	required bool
+
+	// defaultDisplay, when hasDefaultDisplay is true, replaces the default
+	// value in the help output.
+	defaultDisplay    string
+	hasDefaultDisplay bool
+
+	// constraints holds the flag constraints that include the flag.
+	constraints []*flagConstraint
+}
+
+// metaValue is a flag.Value that carries the metadata for a flag. It replaces
+// the flag value when the metadata is attached, so that the metadata lives as
+// long as the flag set and it is shared by the copies of the flag in other
+// flag sets.
+type metaValue struct {
+	flag.Value
+	meta *flagMeta
+}
+
+// IsBoolFlag reports true if the wrapped value is a boolean flag, so that the
+// flag.FlagSet.Parse also treats the flag as a boolean flag.
+func (v *metaValue) IsBoolFlag() bool {
+	fv, ok := v.Value.(interface{ IsBoolFlag() bool })
+	return ok && fv.IsBoolFlag()
+}
+
+// Get returns the value from the wrapped value, if it implements the
+// flag.Getter interface, or nil.
+func (v *metaValue) Get() any {
+	if fv, ok := v.Value.(flag.Getter); ok {
+		return fv.Get()
+	}
+	return nil
+}
+
+func (v *metaValue) String() string {
+	if v == nil || v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+// flagValue returns the value of a flag without the metadata wrapper.
+func flagValue(f *flag.Flag) flag.Value {
+	if mv, ok := f.Value.(*metaValue); ok {
+		return mv.Value
+	}
+	return f.Value
+}
+
+// getFlagMeta returns the metadata for a flag. Returns nil if the flag is not
+// defined, or if it has no metadata and create is false.
+func getFlagMeta(fset *flag.FlagSet, name string, create bool) *flagMeta {
+	f := fset.Lookup(name)
+	if f == nil {
+		return nil
+	}
+	if mv, ok := f.Value.(*metaValue); ok {
+		return mv.meta
+	}
+	if !create {
+		return nil
+	}
+	fm := new(flagMeta)
+	f.Value = &metaValue{Value: f.Value, meta: fm}
+	return fm
+}
+
+// copyFlag defines a flag in the flag set with the value, usage and default
+// value of a flag from another flag set. The copy shares the metadata of the
+// flag.
+func copyFlag(fset *flag.FlagSet, f *flag.Flag) {
+	fset.Var(f.Value, f.Name, f.Usage)
+	fset.Lookup(f.Name).DefValue = f.DefValue
+}
+
+// MarkFlagDeprecated marks a flag as deprecated with the given message. A
+// warning with the message is printed when the deprecated flag is used in the
+// command-line. Returns an error if the flag is not defined.
+//
+// Example:
+//
+//	cli.MarkFlagDeprecated(fset, "addr", "use -host and -port instead")
+func MarkFlagDeprecated(fset *flag.FlagSet, name, msg string) error {
+	if fset.Lookup(name) == nil {
+		return fmt.Errorf("flag not defined: -%s", name)
+	}
+	getFlagMeta(fset, name, true).deprecated = msg
+	return nil
+}
+
+// AliasFlag defines an alias name for an existing flag, so that both names
+// refer to the same flag value, as in "-v" and "--verbose". Aliases are
+// documented together with the canonical flag. Returns an error if the
+// canonical flag is not defined or the alias name is already in use.
+//
+// Example:
+//
+//	fset.BoolVar(&verbose, "verbose", false, "enable verbose output")
+//	cli.AliasFlag(fset, "verbose", "v")
+func AliasFlag(fset *flag.FlagSet, canonical, alias string) error {
+	f := fset.Lookup(canonical)
+	if f == nil {
+		return fmt.Errorf("flag not defined: -%s", canonical)
+	}
+	if fm := getFlagMeta(fset, canonical, false); fm != nil && len(fm.aliasOf) > 0 {
+		return fmt.Errorf("flag -%s is an alias for -%s", canonical, fm.aliasOf)
+	}
+	if fset.Lookup(alias) != nil {
+		return fmt.Errorf("flag -%s is already defined: %w", alias, os.ErrExist)
+	}
+	fset.Var(flagValue(f), alias, f.Usage)
+	getFlagMeta(fset, alias, true).aliasOf = canonical
+	fm := getFlagMeta(fset, canonical, true)
+	fm.aliases = append(fm.aliases, alias)
+	return nil
+}
+
+// canonicalFlag returns the canonical flag if the input is an alias flag.
+func canonicalFlag(fset *flag.FlagSet, f *flag.Flag) *flag.Flag {
+	if fm := getFlagMeta(fset, f.Name, false); fm != nil && len(fm.aliasOf) > 0 {
+		if cf := fset.Lookup(fm.aliasOf); cf != nil {
+			return cf
+		}
+	}
+	return f
+}
+
+// isAliasFlag reports true if the flag is an alias for another flag.
+func isAliasFlag(fset *flag.FlagSet, name string) bool {
+	fm := getFlagMeta(fset, name, false)
+	return fm != nil && len(fm.aliasOf) > 0
+}
+
+// flagAliases returns the alias names for a canonical flag.
+func flagAliases(fset *flag.FlagSet, name string) []string {
+	if fm := getFlagMeta(fset, name, false); fm != nil {
+		return fm.aliases
+	}
+	return nil
+}
+
+type countValue int
+
+func (v *countValue) Set(s string) error {
+	switch s {
+	case "true":
+		*v++
+	case "false":
+		*v = 0
+	default:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return strconv.ErrSyntax
+		}
+		*v = countValue(n)
+	}
+	return nil
+}
+
+func (v *countValue) Get() any { return int(*v) }
+
+func (v *countValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *countValue) IsBoolFlag() bool { return true }
+
+// CountVar defines a repeatable flag with the specified name and usage string
+// that counts the number of times it is given in the command-line. The count
+// is stored in the int pointed to by p. An explicit value, as in "-v=3", sets
+// the count directly and "-v=false" resets it to zero.
+//
+// Example:
+//
+//	var verbosity int
+//	cli.CountVar(fset, &verbosity, "v", "increase verbosity")
+//	// "-v -v -v" sets verbosity to 3
+func CountVar(fset *flag.FlagSet, p *int, name, usage string) {
+	fset.Var((*countValue)(p), name, usage)
+}
+
+type stringSliceValue struct {
+	p       *[]string
+	def     []string
+	changed bool
+}
+
+func (v *stringSliceValue) reset() {
+	*v.p = slices.Clone(v.def)
+	v.changed = false
+}
+
+func (v *stringSliceValue) Set(s string) error {
+	// First value from the command-line replaces the default values.
+	if !v.changed {
+		*v.p = nil
+		v.changed = true
+	}
+	*v.p = append(*v.p, s)
+	return nil
+}
+
+func (v *stringSliceValue) Get() any { return *v.p }
+
+func (v *stringSliceValue) String() string {
+	if v == nil || v.p == nil {
+		return ""
+	}
+	return strings.Join(*v.p, ",")
+}
+
+// StringSliceVar defines a repeatable string flag with the specified name and
+// usage string. Every occurrence of the flag appends its value to the slice
+// pointed to by p. Initial contents of the slice are used as the default value,
+// which is replaced by the values from the command-line, if any.
+//
+// Example:
+//
+//	var headers []string
+//	cli.StringSliceVar(fset, &headers, "header", "add a request header")
+//	// "-header a -header b" sets headers to ["a", "b"]
+func StringSliceVar(fset *flag.FlagSet, p *[]string, name, usage string) {
+	fset.Var(&stringSliceValue{p: p, def: slices.Clone(*p)}, name, usage)
+}
+
+// secretMask replaces the secret flag values in the output.
+const secretMask = "****"
+
+type secretValue struct {
+	p   *string
+	def string
+}
+
+// reset restores the real default value, because the DefValue of the flag
+// holds the masked text.
+func (v *secretValue) reset() {
+	*v.p = v.def
+}
+
+// DefaultText omits the default value from the help output.
+func (v *secretValue) DefaultText() string { return "" }
+
+func (v *secretValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+// Get returns the masked value, so that the secret does not leak through the
+// flag.Getter interface.
+func (v *secretValue) Get() any { return v.String() }
+
+func (v *secretValue) String() string {
+	if v == nil || v.p == nil || len(*v.p) == 0 {
+		return ""
+	}
+	return secretMask
+}
+
+// SecretVar defines a string flag for secrets, like passwords, with the
+// specified name and usage string. The value is stored in the string pointed
+// to by p, but it is omitted from the help output, and it is masked in the
+// -echo output and by the flag.Value methods. Interactive prompts for secret
+// flags do not echo the input; on the platforms where the echo cannot be
+// disabled, missing secret flags are reported as errors instead of prompted
+// for. See [Options.InteractivePrompts].
+//
+// Example:
+//
+//	var password string
+//	cli.SecretVar(fset, &password, "password", "database password")
+//	cli.MarkFlagRequired(fset, "password")
+func SecretVar(fset *flag.FlagSet, p *string, name, usage string) {
+	fset.Var(&secretValue{p: p, def: *p}, name, usage)
+}
+
+// isSecretFlag reports true if the flag is defined by SecretVar.
+func isSecretFlag(f *flag.Flag) bool {
+	_, ok := flagValue(f).(*secretValue)
+	return ok
+}
+
+// byteUnits lists the byte size suffixes in the order they are preferred when
+// a size is printed.
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TiB", 1 << 40},
+	{"TB", 1e12},
+	{"GiB", 1 << 30},
+	{"GB", 1e9},
+	{"MiB", 1 << 20},
+	{"MB", 1e6},
+	{"KiB", 1 << 10},
+	{"KB", 1e3},
+	{"B", 1},
+}
+
+type byteSizeValue int64
+
+func (v *byteSizeValue) Set(s string) error {
+	n, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	*v = byteSizeValue(n)
+	return nil
+}
+
+func (v *byteSizeValue) Get() any { return int64(*v) }
+
+func (v *byteSizeValue) String() string { return formatByteSize(int64(*v)) }
+
+// parseByteSize parses a byte size with an optional unit suffix, like "512",
+// "64KB" or "1.5GiB". Suffixes are case-insensitive.
+func parseByteSize(s string) (int64, error) {
+	str := strings.TrimSpace(s)
+	mult := int64(1)
+	for _, u := range byteUnits {
+		if len(str) > len(u.suffix) && strings.EqualFold(str[len(str)-len(u.suffix):], u.suffix) {
+			str, mult = strings.TrimSpace(str[:len(str)-len(u.suffix)]), u.size
+			break
+		}
+	}
+	if n, err := strconv.ParseInt(str, 10, 64); err == nil {
+		if n < 0 || n > math.MaxInt64/mult {
+			return 0, fmt.Errorf("byte size %q is out of range", s)
+		}
+		return n * mult, nil
+	}
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q", s)
+	}
+	if f < 0 || f*float64(mult) >= math.MaxInt64 {
+		return 0, fmt.Errorf("byte size %q is out of range", s)
+	}
+	return int64(f * float64(mult)), nil
+}
+
+// formatByteSize returns the size in the largest unit that represents it
+// exactly, preferring the binary units.
+func formatByteSize(n int64) string {
+	if n == 0 {
+		return "0"
+	}
+	for _, u := range byteUnits {
+		if n%u.size == 0 {
+			return strconv.FormatInt(n/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// ByteSizeVar defines a byte size flag with the specified name and usage
+// string. The size is stored in the int64 pointed to by p and its current
+// value is used as the default value. Sizes accept an optional KB, KiB, MB,
+// MiB, GB, GiB, TB or TiB suffix and are printed back in the same form.
+//
+// Example:
+//
+//	maxSize := int64(10 << 20)
+//	cli.ByteSizeVar(fset, &maxSize, "max-size", "maximum file `size`")
+//	// help shows (default 10MiB); "-max-size=1.5GB" sets 1500000000
+func ByteSizeVar(fset *flag.FlagSet, p *int64, name, usage string) {
+	fset.Var((*byteSizeValue)(p), name, usage)
+}
+
+// ResetFlags restores all flags in the flag set to their default values, so
+// that the flag set can be parsed again, as when the same commands are run
+// multiple times. Returns an error if a flag value cannot be set to its
+// default value.
+//
+// Example:
+//
+//	for _, args := range requests {
+//	    cli.ResetFlags(fset)
+//	    cli.Run(ctx, cmds, args)
+//	}
+func ResetFlags(fset *flag.FlagSet) error {
+	return resetFlags(fset, nil)
+}
+
+// resetFlags is similar to ResetFlags, but skips the flags for which the skip
+// function returns true.
+func resetFlags(fset *flag.FlagSet, skip func(*flag.Flag) bool) error {
+	if fset == nil {
+		return nil
+	}
+	var errs []error
+	fset.VisitAll(func(f *flag.Flag) {
+		if skip != nil && skip(f) {
+			return
+		}
+		if v, ok := flagValue(f).(interface{ reset() }); ok {
+			v.reset()
+			return
+		}
+		if err := f.Value.Set(f.DefValue); err != nil {
+			errs = append(errs, fmt.Errorf("could not reset flag -%s: %w", f.Name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// flagConstraint is a constraint on a group of flags from a flag set.
+type flagConstraint struct {
+	exclusive bool // true for mutually exclusive, false for required together
+	names     []string
+}
+
+func addFlagConstraint(fset *flag.FlagSet, exclusive bool, names []string) error {
+	if len(names) < 2 {
+		return fmt.Errorf("at least two flags are required: %w", os.ErrInvalid)
+	}
+	for _, name := range names {
+		if fset.Lookup(name) == nil {
+			return fmt.Errorf("flag not defined: -%s", name)
+		}
+	}
+	fc := &flagConstraint{exclusive: exclusive, names: slices.Clone(names)}
+	for _, name := range names {
+		fm := getFlagMeta(fset, name, true)
+		fm.constraints = append(fm.constraints, fc)
+	}
+	return nil
+}
+
+// MarkFlagsMutuallyExclusive marks a group of flags such that at most one of
+// them can be set in the command-line. Returns an error if any of the flags
+// is not defined.
+//
+// Example:
+//
+//	cli.MarkFlagsMutuallyExclusive(fset, "json", "yaml")
+func MarkFlagsMutuallyExclusive(fset *flag.FlagSet, names ...string) error {
+	return addFlagConstraint(fset, true, names)
+}
+
+// MarkFlagsRequiredTogether marks a group of flags such that either all or
+// none of them must be set in the command-line. Returns an error if any of
+// the flags is not defined.
+//
+// Example:
+//
+//	cli.MarkFlagsRequiredTogether(fset, "user", "password")
+func MarkFlagsRequiredTogether(fset *flag.FlagSet, names ...string) error {
+	return addFlagConstraint(fset, false, names)
+}
+
+// checkFlagConstraints verifies the flag constraints of a flag set, given a
+// function that reports if a flag is set in the command-line.
+func checkFlagConstraints(fset *flag.FlagSet, isSet func(*flag.Flag) bool) error {
+	// constraints are found through the flags, so that the constraints of
+	// the copied flags also apply
+	var constraints []*flagConstraint
+	fset.VisitAll(func(f *flag.Flag) {
+		if fm := getFlagMeta(fset, f.Name, false); fm != nil {
+			for _, fc := range fm.constraints {
+				if !slices.Contains(constraints, fc) {
+					constraints = append(constraints, fc)
+				}
+			}
+		}
+	})
+
+	for _, fc := range constraints {
+		var set, unset []string
+		for _, name := range fc.names {
+			if f := fset.Lookup(name); f != nil && isSet(f) {
+				set = append(set, "--"+name)
+			} else {
+				unset = append(unset, "--"+name)
+			}
+		}
+		if fc.exclusive && len(set) > 1 {
+			return newParseError(ErrFlagConstraint, "flags %s are mutually exclusive", joinWords(set))
+		}
+		if !fc.exclusive && len(set) > 0 && len(unset) > 0 {
+			return newParseError(ErrFlagConstraint, "flags %s must be set together; missing %s", joinWords(append(set, unset...)), joinWords(unset))
+		}
+	}
+	return nil
+}
+
+// MarkFlagRequired marks a flag such that it must be set in the command-line.
+// Returns an error if the flag is not defined. Also see
+// [Options.InteractivePrompts].
+//
+// Example:
+//
+//	cli.MarkFlagRequired(fset, "project")
+func MarkFlagRequired(fset *flag.FlagSet, name string) error {
+	if fset.Lookup(name) == nil {
+		return fmt.Errorf("flag not defined: -%s", name)
+	}
+	getFlagMeta(fset, name, true).required = true
+	return nil
+}
+
+// missingFlags returns the required flags of a flag set that are not set in
+// the command-line.
+func missingFlags(fset *flag.FlagSet, isSet func(*flag.Flag) bool) []*flag.Flag {
+	if fset == nil {
+		return nil
+	}
+	var missing []*flag.Flag
+	for _, f := range allFlags(fset) {
+		if fm := getFlagMeta(fset, f.Name, false); fm != nil && fm.required && !isSet(f) {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+// joinWords joins the words as a list in English, like "a, b and c".
+func joinWords(words []string) string {
+	if len(words) < 2 {
+		return strings.Join(words, "")
+	}
+	return strings.Join(words[:len(words)-1], ", ") + " and " + words[len(words)-1]
+}
+
+// SetFlagValidator attaches a validation function to a flag. The function is
+// called with the flag value from the command-line after the value is parsed
+// successfully by the flag. A non-nil error from the validator fails the
+// command-line parsing with an error that names the flag. Returns an error if
+// the flag is not defined.
+//
+// Example:
+//
+//	port := fset.Int("port", 8080, "server port")
+//	cli.SetFlagValidator(fset, "port", func(string) error {
+//	    if *port < 1024 {
+//	        return fmt.Errorf("port must be at least 1024")
+//	    }
+//	    return nil
+//	})
+func SetFlagValidator(fset *flag.FlagSet, name string, fn func(value string) error) error {
+	if fset.Lookup(name) == nil {
+		return fmt.Errorf("flag not defined: -%s", name)
+	}
+	getFlagMeta(fset, name, true).validator = fn
+	return nil
+}
+
+// validateFlag runs the validator for a flag, if any.
+func validateFlag(fset *flag.FlagSet, f *flag.Flag, value string) error {
+	fm := getFlagMeta(fset, f.Name, false)
+	if fm == nil || fm.validator == nil {
+		return nil
+	}
+	if err := fm.validator(value); err != nil {
+		return newParseError(ErrInvalidFlagValue, "invalid value %q for flag -%s: %w", value, f.Name, err)
+	}
+	return nil
+}
+
+// TimeoutVar defines a "timeout" duration flag with the specified default value
+// and stores its value in the time.Duration pointed to by p. When the flag
+// value is non-zero, the context passed to the command is canceled after the
+// timeout.
+//
+// Example:
+//
+//	var timeout time.Duration
+//	cli.TimeoutVar(fset, &timeout, time.Minute)
+func TimeoutVar(fset *flag.FlagSet, p *time.Duration, value time.Duration) {
+	fset.DurationVar(p, "timeout", value, "maximum time allowed for the command to run")
+	getFlagMeta(fset, "timeout", true).timeout = true
+}
+
+// getTimeout returns the timeout for a command, if any. Commands can define
+// their timeout by implementing a Timeout() time.Duration method or by the
+// TimeoutVar flag.
+func getTimeout(c Command, fset *flag.FlagSet) time.Duration {
+	if v, ok := optional(c).(interface{ Timeout() time.Duration }); ok {
+		return v.Timeout()
+	}
+	if fm := getFlagMeta(fset, "timeout", false); fm != nil && fm.timeout {
+		if v, ok := flagValue(fset.Lookup("timeout")).(flag.Getter); ok {
+			if d, ok := v.Get().(time.Duration); ok {
+				return d
+			}
+		}
+	}
+	return 0
+}
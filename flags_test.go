@@ -0,0 +1,399 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCountVar(t *testing.T) {
+	ctx := context.Background()
+
+	run := newTestCmd("run")
+	var verbosity int
+	CountVar(run.flags, &verbosity, "v", "increase verbosity")
+
+	if err := Run(ctx, []Command{run}, []string{"run", "-v", "-v", "--v", "arg"}); err != nil {
+		t.Fatal(err)
+	}
+	if verbosity != 3 {
+		t.Fatalf("want 3, got %d", verbosity)
+	}
+	if len(run.args) != 1 || run.args[0] != "arg" {
+		t.Fatalf("want `arg`, got %v", run.args)
+	}
+
+	if err := Run(ctx, []Command{run}, []string{"run", "-v=5"}); err != nil {
+		t.Fatal(err)
+	}
+	if verbosity != 5 {
+		t.Fatalf("want 5, got %d", verbosity)
+	}
+}
+
+func TestStringSliceVar(t *testing.T) {
+	ctx := context.Background()
+
+	get := newTestCmd("get")
+	var headers []string
+	StringSliceVar(get.flags, &headers, "header", "add a request header")
+	defaults := []string{"x-default"}
+	StringSliceVar(get.flags, &defaults, "default", "flag with default values")
+
+	args := []string{"get", "-header", "a", "-header=b", "--header", "c", "url"}
+	if err := Run(ctx, []Command{get}, args); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !slices.Equal(headers, want) {
+		t.Fatalf("want %v, got %v", want, headers)
+	}
+	if want := []string{"x-default"}; !slices.Equal(defaults, want) {
+		t.Fatalf("want %v, got %v", want, defaults)
+	}
+
+	var sb strings.Builder
+	get.flags.SetOutput(&sb)
+	get.flags.PrintDefaults()
+	if out := sb.String(); !strings.Contains(out, "(default x-default)") || strings.Contains(out, "(default a,b,c)") {
+		t.Fatalf("unexpected defaults: %s", out)
+	}
+}
+
+func TestTimeoutVar(t *testing.T) {
+	ctx := context.Background()
+
+	fset := flag.NewFlagSet("sleep", flag.ContinueOnError)
+	var timeout time.Duration
+	TimeoutVar(fset, &timeout, 0)
+	sleep := NewCommand("sleep", func(ctx context.Context, args []string) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil
+		}
+		<-ctx.Done()
+		return errors.New("interrupted")
+	}, fset, "sleep until timeout")
+
+	if err := Run(ctx, []Command{sleep}, []string{"sleep"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(ctx, []Command{sleep}, []string{"sleep", "-timeout=10ms"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestSetFlagValidator(t *testing.T) {
+	ctx := context.Background()
+
+	serve := newTestCmd("serve")
+	port := serve.flags.Int("port", 8080, "server port")
+	if err := SetFlagValidator(serve.flags, "port", func(string) error {
+		if *port < 1024 {
+			return errors.New("port must be at least 1024")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFlagValidator(serve.flags, "undefined", nil); err == nil {
+		t.Fatalf("want error for undefined flag")
+	}
+
+	if err := Run(ctx, []Command{serve}, []string{"serve", "-port=9090"}); err != nil {
+		t.Fatal(err)
+	}
+	err := Run(ctx, []Command{serve}, []string{"serve", "-port", "80"})
+	if !errors.Is(err, ErrInvalidFlagValue) {
+		t.Fatalf("want ErrInvalidFlagValue, got %v", err)
+	}
+	if want := `serve: invalid value "80" for flag -port: port must be at least 1024`; err.Error() != want {
+		t.Fatalf("want %q, got %q", want, err.Error())
+	}
+}
+
+func TestFlagConstraints(t *testing.T) {
+	ctx := context.Background()
+
+	list := newTestCmd("list")
+	list.flags.Bool("json", false, "json output")
+	list.flags.Bool("yaml", false, "yaml output")
+	list.flags.String("user", "", "user name")
+	list.flags.String("password", "", "user password")
+	if err := MarkFlagsMutuallyExclusive(list.flags, "json", "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkFlagsRequiredTogether(list.flags, "user", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkFlagsMutuallyExclusive(list.flags, "json", "undefined"); err == nil {
+		t.Fatalf("want error for undefined flag")
+	}
+
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"list", "-json"}, ""},
+		{[]string{"list", "-yaml", "-user=u", "-password=p"}, ""},
+		{[]string{"list", "-json", "-yaml"}, "list: flags --json and --yaml are mutually exclusive"},
+		{[]string{"list", "-user=u"}, "list: flags --user and --password must be set together; missing --password"},
+	}
+	for _, tt := range tests {
+		err := Run(ctx, []Command{list}, tt.args)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("Run(%q): got error %v, want nil", tt.args, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrFlagConstraint) || err.Error() != tt.wantErr {
+			t.Errorf("Run(%q): got error %v, want %q", tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAliasFlag(t *testing.T) {
+	ctx := context.Background()
+
+	run := newTestCmd("run")
+	var verbose bool
+	run.flags.BoolVar(&verbose, "verbose", false, "enable verbose output")
+	if err := AliasFlag(run.flags, "verbose", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AliasFlag(run.flags, "missing", "m"); err == nil {
+		t.Fatalf("want error for undefined flag")
+	}
+	if err := AliasFlag(run.flags, "verbose", "v"); err == nil {
+		t.Fatalf("want error for duplicate alias")
+	}
+
+	for _, arg := range []string{"-v", "--verbose"} {
+		verbose = false
+		if err := Run(ctx, []Command{run}, []string{"run", arg}); err != nil {
+			t.Fatal(err)
+		}
+		if !verbose {
+			t.Fatalf("want %s to set the flag", arg)
+		}
+	}
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout}
+	if err := opts.Run(ctx, []Command{run}, []string{"help", "run"}); err != nil {
+		t.Fatal(err)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "  -v, --verbose\n") {
+		t.Fatalf("want aliases together, got %q", out)
+	}
+	if strings.Count(out, "enable verbose output") != 1 {
+		t.Fatalf("want the alias documented once, got %q", out)
+	}
+}
+
+func TestMarkFlagRequired(t *testing.T) {
+	ctx := context.Background()
+
+	deploy := newTestCmd("deploy")
+	project := deploy.flags.String("project", "", "project `name`")
+	region := deploy.flags.String("region", "", "deployment region")
+	if err := MarkFlagRequired(deploy.flags, "project"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkFlagRequired(deploy.flags, "region"); err != nil {
+		t.Fatal(err)
+	}
+	if err := MarkFlagRequired(deploy.flags, "undefined"); err == nil {
+		t.Fatalf("want error for undefined flag")
+	}
+	cmds := []Command{deploy}
+
+	if err := Run(ctx, cmds, []string{"deploy", "-project=p", "-region=r"}); err != nil {
+		t.Fatal(err)
+	}
+	err := Run(ctx, cmds, []string{"deploy", "-region=r"})
+	if want := "deploy: required flag --project is not set"; !errors.Is(err, ErrFlagConstraint) || err.Error() != want {
+		t.Fatalf("want %q, got %v", want, err)
+	}
+	if _, _, err := RunCapture(ctx, cmds, []string{"help", "deploy"}); err != nil {
+		t.Fatalf("want help without the required flags, got %v", err)
+	}
+
+	// non-terminal input falls back to the error
+	opts := &Options{InteractivePrompts: true, Stdin: strings.NewReader("x\n"), Stderr: new(strings.Builder)}
+	if err := opts.Run(ctx, cmds, []string{"deploy"}); !errors.Is(err, ErrFlagConstraint) {
+		t.Fatalf("want ErrFlagConstraint for non-terminal input, got %v", err)
+	}
+
+	saved := isTerminal
+	defer func() { isTerminal = saved }()
+	isTerminal = func(any) bool { return true }
+
+	var stderr strings.Builder
+	opts = &Options{InteractivePrompts: true, Stdin: strings.NewReader("widgets\nus-east\n"), Stderr: &stderr}
+	if err := opts.Run(ctx, cmds, []string{"deploy"}); err != nil {
+		t.Fatal(err)
+	}
+	if *project != "widgets" || *region != "us-east" {
+		t.Fatalf("want prompted values, got %q and %q", *project, *region)
+	}
+	if want := "project name (--project): deployment region (--region): "; stderr.String() != want {
+		t.Fatalf("got prompts %q, want %q", stderr.String(), want)
+	}
+}
+
+func TestSecretVar(t *testing.T) {
+	ctx := context.Background()
+
+	login := newTestCmd("login")
+	password := "hunter2"
+	SecretVar(login.flags, &password, "password", "account password")
+
+	stdout, _, err := RunCapture(ctx, []Command{login}, []string{"help", "login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stdout, "hunter2") || !strings.Contains(stdout, "account password\n") {
+		t.Fatalf("want no default value, got %q", stdout)
+	}
+
+	var stderr strings.Builder
+	opts := &Options{EchoFlag: true, Stderr: &stderr}
+	if err := opts.Run(ctx, []Command{login}, []string{"-echo", "login", "-password=s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	if password != "s3cret" {
+		t.Fatalf("want the real value stored, got %q", password)
+	}
+	if out := stderr.String(); strings.Contains(out, "s3cret") || !strings.Contains(out, "--password=****") {
+		t.Fatalf("want masked value in echo, got %q", out)
+	}
+	if v := login.flags.Lookup("password").Value.String(); v != "****" {
+		t.Fatalf("want masked flag value, got %q", v)
+	}
+	if err := ResetFlags(login.flags); err != nil {
+		t.Fatal(err)
+	}
+	if password != "hunter2" {
+		t.Fatalf("want the real default restored, got %q", password)
+	}
+
+	saved := isTerminal
+	defer func() { isTerminal = saved }()
+	isTerminal = func(any) bool { return true }
+
+	MarkFlagRequired(login.flags, "password")
+	stderr.Reset()
+	opts = &Options{InteractivePrompts: true, Stdin: strings.NewReader("pa55\n"), Stderr: &stderr}
+	err = opts.Run(ctx, []Command{login}, []string{"login"})
+	if !canDisableEcho {
+		// secrets are not prompted for where the input would be echoed
+		if !errors.Is(err, ErrFlagConstraint) || stderr.Len() != 0 {
+			t.Fatalf("want ErrFlagConstraint without a prompt, got %v and %q", err, stderr.String())
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "pa55" {
+		t.Fatalf("want prompted password, got %q", password)
+	}
+	if want := "account password (--password): \n"; stderr.String() != want {
+		t.Fatalf("got prompt %q, want %q", stderr.String(), want)
+	}
+}
+
+func TestByteSizeVar(t *testing.T) {
+	ctx := context.Background()
+
+	upload := newTestCmd("upload")
+	maxSize := int64(10 << 20)
+	ByteSizeVar(upload.flags, &maxSize, "max-size", "maximum file size")
+
+	stdout, _, err := RunCapture(ctx, []Command{upload}, []string{"help", "upload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "(default 10MiB)") {
+		t.Fatalf("want human readable default value, got %q", stdout)
+	}
+
+	for s, want := range map[string]int64{
+		"512":    512,
+		"64KB":   64000,
+		"64kib":  65536,
+		"1.5GiB": 3 << 29,
+		"2 MB":   2000000,
+		"1TiB":   1 << 40,
+	} {
+		if err := Run(ctx, []Command{upload}, []string{"upload", "-max-size", s}); err != nil {
+			t.Fatal(err)
+		}
+		if maxSize != want {
+			t.Errorf("-max-size=%s: got %d, want %d", s, maxSize, want)
+		}
+	}
+
+	for _, s := range []string{"", "MiB", "-1KB", "10XB", "9000000TiB"} {
+		if err := Run(ctx, []Command{upload}, []string{"upload", "-max-size", s}); !errors.Is(err, ErrInvalidFlagValue) {
+			t.Errorf("-max-size=%q: want ErrInvalidFlagValue, got %v", s, err)
+		}
+	}
+
+	for n, want := range map[int64]string{0: "0", 1023: "1023B", 2048: "2KiB", 3000000: "3MB"} {
+		if got := formatByteSize(n); got != want {
+			t.Errorf("formatByteSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestFlagMetaOwnership(t *testing.T) {
+	ctx := context.Background()
+
+	// Flag sets with metadata are released once the commands are unused.
+	var released atomic.Int32
+	for range 10 {
+		cmd := newTestCmd("run")
+		cmd.flags.Bool("all", false, "run all")
+		if err := MarkFlagDeprecated(cmd.flags, "all", "all is the default"); err != nil {
+			t.Fatal(err)
+		}
+		// flag sets are in a cycle through their Usage, so the metadata is
+		// watched instead
+		runtime.SetFinalizer(getFlagMeta(cmd.flags, "all", false), func(*flagMeta) { released.Add(1) })
+		opts := &Options{Stderr: io.Discard, IsolateCommandLine: true}
+		if err := opts.Run(ctx, []Command{cmd}, []string{"run", "-all"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 10 && released.Load() == 0; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if released.Load() == 0 {
+		t.Fatalf("want flag sets with metadata to be released")
+	}
+
+	// Flags with metadata still work with the flag package.
+	fset := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := fset.Bool("verbose", false, "verbose output")
+	if err := MarkFlagRequired(fset, "verbose"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fset.Parse([]string{"-verbose", "x"}); err != nil || !*verbose || fset.NArg() != 1 {
+		t.Fatalf("want boolean flag with metadata, got %v, %v and %q", err, *verbose, fset.Args())
+	}
+	if v, ok := fset.Lookup("verbose").Value.(flag.Getter); !ok || v.Get() != true {
+		t.Fatalf("want flag.Getter value through the metadata")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// flagPlaceholder returns the placeholder name for the flag value and the
+// usage string with the back-quotes removed. A back-quoted name in the usage
+// string is used as the placeholder, same as the flag package. Otherwise, the
+// placeholder is derived from the type of the flag value. Boolean flags have
+// no placeholder.
+func flagPlaceholder(f *flag.Flag) (string, string) {
+	name, usage := flag.UnquoteUsage(f)
+	if strings.Contains(f.Usage, "`") {
+		return name, usage
+	}
+	if v, ok := flagValue(f).(interface{ IsBoolFlag() bool }); ok && v.IsBoolFlag() {
+		return "", usage
+	}
+	if v, ok := flagValue(f).(flag.Getter); ok {
+		switch v.Get().(type) {
+		case string:
+			return "string", usage
+		case int, int64:
+			return "int", usage
+		case uint, uint64:
+			return "uint", usage
+		case float64:
+			return "float", usage
+		case time.Duration:
+			return "duration", usage
+		case []string:
+			return "strings", usage
+		}
+	}
+	return "value", usage
+}
+
+// isZeroValue reports whether the string represents the zero value for a
+// flag, same as the flag package.
+func isZeroValue(f *flag.Flag, value string) bool {
+	typ := reflect.TypeOf(flagValue(f))
+	var z reflect.Value
+	if typ.Kind() == reflect.Pointer {
+		z = reflect.New(typ.Elem())
+	} else {
+		z = reflect.Zero(typ)
+	}
+	defer func() { recover() }()
+	return value == z.Interface().(flag.Value).String()
+}
+
+// allFlags returns all flags in the flag set in lexicographical order. Alias
+// flags are skipped, because they are documented with the canonical flags.
+func allFlags(fset *flag.FlagSet) []*flag.Flag {
+	var flags []*flag.Flag
+	fset.VisitAll(func(f *flag.Flag) {
+		if !isAliasFlag(fset, f.Name) {
+			flags = append(flags, f)
+		}
+	})
+	return flags
+}
+
+// flagDisplayName returns the flag name with the dash prefix, along with the
+// aliases, if any, as in "-v, --verbose". Single-character names are listed
+// first.
+func flagDisplayName(pal palette, fset *flag.FlagSet, f *flag.Flag) string {
+	names := append([]string{f.Name}, flagAliases(fset, f.Name)...)
+	sort.SliceStable(names, func(i, j int) bool {
+		return len(names[i]) == 1 && len(names[j]) != 1
+	})
+	for i, name := range names {
+		if len(name) == 1 {
+			names[i] = pal.name("-" + name)
+		} else {
+			names[i] = pal.name("--" + name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// printFlagDefaults prints the flags, similar to the flag.PrintDefaults, but
+// with a type placeholder for every non-boolean flag and the default values
+// from flagDefault. Multi-character flag names are printed with the
+// double-dash prefix.
+func printFlagDefaults(w io.Writer, pal palette, fset *flag.FlagSet, flags []*flag.Flag) {
+	for _, f := range flags {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "  %s", flagDisplayName(pal, fset, f))
+		name, usage := flagPlaceholder(f)
+		if len(name) > 0 {
+			sb.WriteString(" ")
+			sb.WriteString(name)
+		}
+		sb.WriteString("\n    \t")
+		sb.WriteString(strings.ReplaceAll(usage, "\n", "\n    \t"))
+
+		if def := flagDefault(fset, f); len(def) > 0 {
+			fmt.Fprintf(&sb, " (default %s)", def)
+		}
+		fmt.Fprintln(w, sb.String())
+	}
+}
+
+// flagDefault returns the default value for a flag as displayed in the help
+// output. The text set by SetDefaultDisplay takes precedence. Flag values
+// implementing the DefaultText() string method choose their own text, which
+// is useful for the composite values whose DefValue is not meaningful to the
+// users; an empty text omits the default. Otherwise, the DefValue is used
+// unless it is the zero value, with quotes for the string flags.
+func flagDefault(fset *flag.FlagSet, f *flag.Flag) string {
+	if display, ok := defaultDisplay(fset, f.Name); ok {
+		return display
+	}
+	if v, ok := flagValue(f).(interface{ DefaultText() string }); ok {
+		return v.DefaultText()
+	}
+	if isZeroValue(f, f.DefValue) {
+		return ""
+	}
+	if v, ok := flagValue(f).(flag.Getter); ok && reflect.ValueOf(v.Get()).Kind() == reflect.String {
+		return fmt.Sprintf("%q", f.DefValue)
+	}
+	return f.DefValue
+}
+
+// SetDefaultDisplay sets the text that is shown as the default value of a
+// flag in the help output, instead of the actual default value, which may
+// vary between the users or the machines, as for the paths computed at
+// runtime. An empty text omits the default. The actual default value is not
+// changed. Returns an error if the flag is not defined.
+//
+// Example:
+//
+//	fset.StringVar(&config, "config", filepath.Join(home, ".myapp/config"), "config file")
+//	cli.SetDefaultDisplay(fset, "config", "~/.myapp/config")
+func SetDefaultDisplay(fset *flag.FlagSet, name, display string) error {
+	if fset.Lookup(name) == nil {
+		return fmt.Errorf("flag not defined: -%s", name)
+	}
+	fm := getFlagMeta(fset, name, true)
+	fm.defaultDisplay, fm.hasDefaultDisplay = display, true
+	return nil
+}
+
+// defaultDisplay returns the text set by SetDefaultDisplay for a flag, if
+// any.
+func defaultDisplay(fset *flag.FlagSet, name string) (string, bool) {
+	if fm := getFlagMeta(fset, name, false); fm != nil && fm.hasDefaultDisplay {
+		return fm.defaultDisplay, true
+	}
+	return "", false
+}
+
+// defaultFlagCategory is the category for the flags without a category.
+const defaultFlagCategory = "Options"
+
+// SetFlagCategory assigns a category to a flag. Flags of a command are grouped
+// by their categories in the help output. Flags without a category are listed
+// under the "Options" category. Returns an error if the flag is not defined.
+//
+// Example:
+//
+//	cli.SetFlagCategory(fset, "connect-host", "Connection")
+//	cli.SetFlagCategory(fset, "connect-port", "Connection")
+func SetFlagCategory(fset *flag.FlagSet, name, category string) error {
+	if fset.Lookup(name) == nil {
+		return fmt.Errorf("flag not defined: -%s", name)
+	}
+	getFlagMeta(fset, name, true).category = category
+	return nil
+}
+
+// groupFlagsByCategory returns the flags grouped by their categories and the
+// categories in sorted order. Returns nil if none of the flags has a category.
+func groupFlagsByCategory(fset *flag.FlagSet) (map[string][]*flag.Flag, []string) {
+	groups := make(map[string][]*flag.Flag)
+	tagged := false
+	for _, f := range allFlags(fset) {
+		category := defaultFlagCategory
+		if fm := getFlagMeta(fset, f.Name, false); fm != nil && len(fm.category) > 0 {
+			category, tagged = fm.category, true
+		}
+		groups[category] = append(groups[category], f)
+	}
+	if !tagged {
+		return nil, nil
+	}
+	return groups, slices.Sorted(maps.Keys(groups))
+}
@@ -3,13 +3,19 @@
 package cli
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type groupCmd struct {
@@ -17,11 +23,101 @@ type groupCmd struct {
 	subcmds    []Command
 	specialCmd string
 	purpose    string
+
+	// description is the long documentation, which is only set on the root
+	// group.
+	description string
+
+	// opts is only set on the root group.
+	opts *Options
+
+	// echo holds the value for the -echo flag.
+	echo bool
+
+	// quiet holds the value for the -quiet flag.
+	quiet bool
+
+	// terminatedAt holds the index of the first argument after the "--"
+	// argument in the arguments for the command, or -1 when no "--" argument
+	// stops the parsing. With the PreserveTerminator option, the "--"
+	// argument is at the previous index.
+	terminatedAt int
+
+	// warnings holds the warnings for the command-line, which are printed
+	// after the parsing, so that a -quiet flag anywhere in the command-line
+	// can suppress them.
+	warnings []string
+
+	// setFlags holds the flags in the order they are set by the command-line.
+	setFlags []setFlag
+
+	// configFlags holds the flags that are set by the config file.
+	configFlags []*flag.Flag
+
+	// errFlags holds the flag set for the last flag parsing error, if any.
+	errFlags *flag.FlagSet
+
+	// specialFlags holds the flags for the selected built-in command, if any.
+	specialFlags *flag.FlagSet
+
+	// jsonOutput holds the value for the -json flag of the commands built-in.
+	jsonOutput bool
+
+	// recursive holds the value for the -all flag of the commands built-in.
+	recursive bool
+
+	// helpAll holds the value for the -all flag of the help built-in.
+	helpAll bool
+
+	// path holds the resolved command path for the last run, if any.
+	path []string
+}
+
+// resetState clears the state from a previous run of the root group.
+func (gc *groupCmd) resetState() {
+	gc.specialCmd = ""
+	gc.setFlags = nil
+	gc.configFlags = nil
+	gc.errFlags = nil
+	gc.specialFlags = nil
+	gc.jsonOutput, gc.recursive, gc.helpAll = false, false, false
+	gc.path = nil
+}
+
+// newSpecialFlags returns the flags for a built-in command.
+func (gc *groupCmd) newSpecialFlags(special string) *flag.FlagSet {
+	fset := flag.NewFlagSet(special, flag.ContinueOnError)
+	// The aliases are defined on a new flag set, so AliasFlag cannot fail.
+	switch special {
+	case "help":
+		fset.BoolVar(&gc.helpAll, "all", false, "list all commands in the subtree")
+		AliasFlag(fset, "all", "a")
+	case "commands":
+		fset.BoolVar(&gc.jsonOutput, "json", false, "print the command tree in JSON format")
+		fset.BoolVar(&gc.recursive, "recursive", false, "list all commands in the subtree")
+		AliasFlag(fset, "recursive", "all")
+	}
+	return fset
+}
+
+// setFlag records a flag occurrence in the command-line.
+type setFlag struct {
+	flag     *flag.Flag
+	name     string
+	value    string
+	hasValue bool
+}
+
+// isSet reports true if the flag is set by the command-line.
+func (gc *groupCmd) isSet(f *flag.Flag) bool {
+	return slices.ContainsFunc(gc.setFlags, func(sf setFlag) bool {
+		return sf.flag == f
+	})
 }
 
 // NewGroup creates a subcommand group with the specified name, purpose, and
 // subcommands. Returns a Command, enabling nested command hierarchies. Returns
-// nil if group name is empty.
+// nil if group name is invalid; see [CheckName].
 //
 // Example:
 //
@@ -29,7 +125,7 @@ type groupCmd struct {
 //	stopCmd := cli.NewCommand("stop", stopFunc, nil, "Stop server")
 //	group := cli.NewGroup("server", "Server operations", startCmd, stopCmd)
 func NewGroup(name, purpose string, cmds ...Command) Command {
-	if len(name) == 0 {
+	if CheckName(name) != nil {
 		return nil
 	}
 	return &groupCmd{
@@ -39,6 +135,78 @@ func NewGroup(name, purpose string, cmds ...Command) Command {
 	}
 }
 
+// NewGroupWithFlags is similar to [NewGroup], but also defines group-level
+// flags. The group flags are accepted after the group name in the command-line
+// and are inherited by all subcommands of the group. The flag.FlagSet is
+// optional; if nil, no group flags are supported. The error handling of the
+// flag.FlagSet is preserved, same as [NewCommand].
+//
+// Example:
+//
+//	var flags flag.FlagSet
+//	config := flags.String("config", "", "server config file")
+//	group := cli.NewGroupWithFlags("server", "Server operations", &flags, startCmd, stopCmd)
+//	// Accepts "server -config=x start"
+func NewGroupWithFlags(name, purpose string, fset *flag.FlagSet, cmds ...Command) Command {
+	if CheckName(name) != nil {
+		return nil
+	}
+	if fset == nil {
+		fset = flag.NewFlagSet(name, flag.ContinueOnError)
+	} else {
+		fset.Init(name, fset.ErrorHandling())
+	}
+	return &groupCmd{
+		flags:   fset,
+		subcmds: cmds,
+		purpose: purpose,
+	}
+}
+
+// AddCommand appends subcommands to a group created by [NewGroup] or
+// [NewGroupWithFlags], which enables building the command tree incrementally.
+// Returns an error if g is not such a group, if a subcommand is nil or has an
+// invalid name, or if a subcommand name is already used in the group. Names
+// that differ only in case are also rejected, because they collide when the
+// commands are matched with the [Options.CaseInsensitive].
+//
+// Example:
+//
+//	group := cli.NewGroup("plugin", "Plugin commands")
+//	for _, p := range plugins {
+//	    if err := cli.AddCommand(group, p.Command()); err != nil {
+//	        return err
+//	    }
+//	}
+func AddCommand(g Command, sub ...Command) error {
+	gc, ok := g.(*groupCmd)
+	if !ok {
+		return fmt.Errorf("command is not a group: %w", os.ErrInvalid)
+	}
+	// keys fold the case, same as the CaseInsensitive option
+	folded := &Options{CaseInsensitive: true}
+	names := make(map[string]bool)
+	for _, c := range gc.subcmds {
+		names[folded.cmdKey(cmdName(c))] = true
+	}
+	for _, c := range sub {
+		if c == nil {
+			return fmt.Errorf("command cannot be nil: %w", os.ErrInvalid)
+		}
+		name := cmdName(c)
+		if err := CheckName(name); err != nil {
+			return err
+		}
+		if key := folded.cmdKey(name); names[key] {
+			return fmt.Errorf("command %q is already defined in group %q: %w", name, gc.flags.Name(), os.ErrExist)
+		} else {
+			names[key] = true
+		}
+	}
+	gc.subcmds = append(gc.subcmds, sub...)
+	return nil
+}
+
 var specialCmds = []string{"help", "flags", "commands"}
 
 // Command implements Command interface.
@@ -47,94 +215,352 @@ func (gc *groupCmd) Command() (string, *flag.FlagSet, CmdFunc) {
 }
 
 func (gc *groupCmd) printFlags(ctx context.Context, w io.Writer, cmdpath []*cmdData) error {
-	fs := cmdpath[len(cmdpath)-1].fset
-	fs.SetOutput(w)
-	fs.PrintDefaults()
+	printFlagSections(w, gc.opts, gc.opts.palette(w), cmdpath)
 	return nil
 }
 
 func (gc *groupCmd) printCommands(ctx context.Context, w io.Writer, cmdpath []*cmdData) error {
-	subcmds := getSubcommands(cmdpath)
-	for _, sub := range subcmds {
-		if len(sub[1]) > 0 {
-			fmt.Fprintf(w, "\t%-15s  %s\n", sub[0], sub[1])
-		} else {
-			fmt.Fprintf(w, "\t%-15s\n", sub[0])
-		}
-	}
+	printCommandList(w, gc.opts.palette(w), getSubcommands(gc.opts, cmdpath))
 	return nil
 }
 
+// isPassThrough reports true if the command wants all arguments after the
+// first non-flag argument passed through as is.
+func isPassThrough(c Command) bool {
+	if v, ok := optional(c).(interface{ PassThrough() bool }); ok {
+		return v.PassThrough()
+	}
+	return false
+}
+
 type cmdData struct {
+	name string
 	fset *flag.FlagSet
 	fun  CmdFunc
 	cmd  Command
 }
 
+// newCmdDataMap returns the cmdData for the commands indexed by their lookup
+// keys.
+func (gc *groupCmd) newCmdDataMap(cmds []Command) map[string]*cmdData {
+	m := make(map[string]*cmdData)
+	for _, c := range cmds {
+		// lazy commands are constructed only when they are selected
+		if _, ok := c.(*lazyCmd); ok {
+			m[gc.opts.cmdKey(cmdName(c))] = &cmdData{name: cmdName(c), cmd: c}
+			continue
+		}
+		name, fs, fn := c.Command()
+		m[gc.opts.cmdKey(name)] = &cmdData{
+			name: name,
+			fset: fs,
+			fun:  fn,
+			cmd:  c,
+		}
+	}
+	return m
+}
+
+// materialize returns the cmdData for the constructed command if the input
+// is a lazy command.
+func materialize(cd *cmdData) *cmdData {
+	if lc, ok := cd.cmd.(*lazyCmd); ok {
+		built := lc.get()
+		_, fs, fn := built.Command()
+		return &cmdData{name: lc.name, fset: fs, fun: fn, cmd: built}
+	}
+	return cd
+}
+
+// hasHelpFlag reports true if the arguments include a -h or -help flag before
+// the "--" terminator.
+func hasHelpFlag(args []string) bool {
+	for _, s := range args {
+		if s == "--" {
+			return false
+		}
+		if isHelpFlag(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDefinedFlag reports true if the argument is a flag known to the lookup
+// function, including the help flags, with or without a value.
+func isDefinedFlag(s string, lookup func(string) (*flag.Flag, *flag.FlagSet, bool)) bool {
+	if len(s) < 2 || s[0] != '-' || isNegativeNumber(s, lookup) {
+		return false
+	}
+	if isHelpFlag(s) {
+		return true
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(s[1:], "-"), "=")
+	_, _, ok := lookup(name)
+	return ok
+}
+
+// isHelpFlag reports true if the argument is a -h or -help flag, with one or
+// two dashes and an optional value, as in "--help=true". A false value, as in
+// "-help=false", doesn't request the help.
+func isHelpFlag(s string) bool {
+	name, value, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(s, "-"), "-"), "=")
+	if len(s) < 2 || s[0] != '-' || (name != "h" && name != "help") {
+		return false
+	}
+	return !hasValue || wantsHelp(value)
+}
+
+// wantsHelp reports false if the help flag value is a valid false boolean.
+// Other values, including the invalid booleans, request the help.
+func wantsHelp(value string) bool {
+	b, err := strconv.ParseBool(value)
+	return err != nil || b
+}
+
 func (gc *groupCmd) resolve(ctx context.Context, args []string) ([]*cmdData, []string, error) {
+	cmdpath, rest, err := gc.parse(ctx, args)
+	if err != nil && hasHelpFlag(args) {
+		// Help is always available, even when the other arguments are invalid.
+		gc.specialCmd = "help"
+		return gc.resolveHelp(args), nil, nil
+	}
+	if err != nil {
+		return nil, nil, gc.withUsage(gc.resolveHelp(args), gc.handleFlagError(err))
+	}
+	return cmdpath, rest, nil
+}
+
+// withUsage returns a copy of the parsing error that renders the help text for
+// the command path when its usage is requested. Other errors are returned as
+// is.
+func (gc *groupCmd) withUsage(cmdpath []*cmdData, err error) error {
+	pe, ok := err.(*ParseError)
+	if !ok {
+		return err
+	}
+	return &ParseError{kind: pe.kind, err: pe.err, opts: gc.opts, cmdpath: cmdpath}
+}
+
+// resolveHelp returns the command path for the help output, by following the
+// arguments that match the subcommands and ignoring everything else.
+func (gc *groupCmd) resolveHelp(args []string) []*cmdData {
+	cmdpath := []*cmdData{{fset: gc.flags, cmd: gc}}
+	cmdDataMap := gc.newCmdDataMap(gc.subcmds)
+	for _, s := range args {
+		if s == "--" {
+			break
+		}
+		subcmd, ok := cmdDataMap[gc.opts.cmdKey(s)]
+		if !ok || !gc.opts.isAllowed(append(cmdNames(cmdpath), subcmd.name)) {
+			continue
+		}
+		subcmd = materialize(subcmd)
+		cmdpath = append(cmdpath, subcmd)
+		if sg, ok := subcmd.cmd.(*groupCmd); ok {
+			cmdDataMap = gc.newCmdDataMap(sg.subcmds)
+		} else {
+			break
+		}
+	}
+	return cmdpath
+}
+
+// parse resolves the command path and parses the flags from the arguments.
+func (gc *groupCmd) parse(ctx context.Context, args []string) ([]*cmdData, []string, error) {
+	defer gc.printWarnings()
+
+	gc.terminatedAt = -1
 	type boolFlag interface {
 		flag.Value
 		IsBoolFlag() bool
 	}
 
-	cmdDataMap := make(map[string]*cmdData)
+	cmdDataMap := gc.newCmdDataMap(gc.subcmds)
 	prepCmdDataMap := func(cmds []Command) {
-		m := make(map[string]*cmdData)
-		for _, c := range cmds {
-			name, fs, fn := c.Command()
-			m[name] = &cmdData{
-				fset: fs,
-				fun:  fn,
-				cmd:  c,
-			}
-		}
-		cmdDataMap = m
+		cmdDataMap = gc.newCmdDataMap(cmds)
 	}
-	prepCmdDataMap(gc.subcmds)
 
 	cmdpath := []*cmdData{
 		{
-			fset: flag.CommandLine,
+			fset: gc.flags,
 			cmd:  gc,
 		},
 	}
 
-	lookup := func(s string) (*flag.Flag, bool) {
+	// fromCommandLine reports true for the flags from the flag.CommandLine,
+	// which are not reset, because they are usually parsed once by the program.
+	fromCommandLine := func(f *flag.Flag) bool {
+		return flag.CommandLine.Lookup(f.Name) != nil
+	}
+	if gc.opts.ResetFlagValues {
+		if err := resetFlags(gc.flags, fromCommandLine); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	lookup := func(s string) (*flag.Flag, *flag.FlagSet, bool) {
+		if gc.specialFlags != nil {
+			if f := gc.specialFlags.Lookup(s); f != nil {
+				return canonicalFlag(gc.specialFlags, f), gc.specialFlags, true
+			}
+		}
 		for i := len(cmdpath) - 1; i >= 0; i-- {
 			if f := cmdpath[i].fset.Lookup(s); f != nil {
-				return f, true
+				return canonicalFlag(cmdpath[i].fset, f), cmdpath[i].fset, true
 			}
 		}
-		return nil, false
+		return nil, nil, false
 	}
 
+	parser := gc.opts.flagParser()
+
+	// lookupPrefix returns the flag whose name starts with the input, when
+	// there is exactly one such flag in the flag sets searched by the lookup.
+	// Returns a nil flag if there is no match.
+	lookupPrefix := func(s string) (*flag.Flag, *flag.FlagSet, error) {
+		var matches []string
+		var match *flag.Flag
+		var matchSet *flag.FlagSet
+		visit := func(fs *flag.FlagSet) {
+			fs.VisitAll(func(f *flag.Flag) {
+				if !strings.HasPrefix(f.Name, s) {
+					return
+				}
+				// aliases and the shadowed parent flags are not ambiguous
+				f = canonicalFlag(fs, f)
+				if slices.Contains(matches, f.Name) {
+					return
+				}
+				matches = append(matches, f.Name)
+				match, matchSet = f, fs
+			})
+		}
+		if gc.specialFlags != nil {
+			visit(gc.specialFlags)
+		}
+		for i := len(cmdpath) - 1; i >= 0; i-- {
+			visit(cmdpath[i].fset)
+		}
+		if len(matches) > 1 {
+			sort.Strings(matches)
+			return nil, nil, newParseError(ErrAmbiguousFlag, "ambiguous flag: -%s (candidates: -%s)", s, strings.Join(matches, ", -"))
+		}
+		return match, matchSet, nil
+	}
+
+	// warned tracks the deprecated flags that are already reported.
+	warned := make(map[*flag.Flag]bool)
+	warnDeprecatedFlag := func(f *flag.Flag, fs *flag.FlagSet) {
+		if gc.opts.deprecationsSilenced() {
+			return
+		}
+		if fm := getFlagMeta(fs, f.Name, false); fm != nil && len(fm.deprecated) > 0 && !warned[f] {
+			warned[f] = true
+			gc.warn("flag -%s is deprecated: %s", f.Name, fm.deprecated)
+		}
+	}
+
+	// available returns the subcommand names that are valid at the current
+	// position in the command-line.
+	available := func() []string {
+		var names []string
+		for _, c := range cmdDataMap {
+			if gc.opts.isAllowed(append(cmdNames(cmdpath), c.name)) {
+				names = append(names, c.name)
+			}
+		}
+		sort.Strings(names)
+		if len(cmdpath) == 1 {
+			for _, special := range specialCmds {
+				if gc.opts.isEnabled(special) {
+					names = append(names, gc.opts.specialName(special))
+				}
+			}
+		}
+		return names
+	}
+
+	// positional holds the non-flag arguments collected when flags are
+	// interspersed with the arguments.
+	var positional []string
+
+	// terminated is true when the "--" argument stops the parsing.
+	var terminated bool
+
 	var i int
 	for i = 0; i < len(args); i++ {
 		s := args[i]
 
 		// stop resolving subcmds and flags
 		if s == "--" {
-			i++
+			terminated = true
+			if !gc.opts.PreserveTerminator {
+				i++
+			}
 			break
 		}
 
 		// Non-flag argument
-		if len(s) < 2 || s[0] != '-' {
+		if len(s) < 2 || s[0] != '-' || isNegativeNumber(s, lookup) {
 			// non-flag argument to the last subcmd
 			if len(cmdDataMap) == 0 {
+				if gc.opts.InterspersedFlags && !isPassThrough(cmdpath[len(cmdpath)-1].cmd) {
+					positional = append(positional, s)
+					continue
+				}
 				break
 			}
 
-			subcmd, ok := cmdDataMap[s]
+			subcmd, ok := cmdDataMap[gc.opts.cmdKey(s)]
 			if !ok {
 				// handle one of special commands: help, flags, commands
-				if len(cmdpath) == 1 && slices.Contains(specialCmds, s) {
-					gc.specialCmd = s
+				if special, ok := gc.opts.lookupSpecial(s); len(cmdpath) == 1 && ok {
+					gc.specialCmd = special
+					gc.specialFlags = gc.newSpecialFlags(special)
 					continue
 				}
-				return nil, nil, fmt.Errorf("command not defined: %s", s)
+				// help is also accepted inside the groups, as in "server help start"
+				if special, ok := gc.opts.lookupSpecial(s); ok && special == "help" && len(gc.specialCmd) == 0 {
+					gc.specialCmd = special
+					gc.specialFlags = gc.newSpecialFlags(special)
+					continue
+				}
+			}
+			// prefix matching only runs after the exact and built-in names
+			if !ok && gc.opts.AbbreviatedCommands {
+				var matches []*cmdData
+				for key, c := range cmdDataMap {
+					if strings.HasPrefix(key, gc.opts.cmdKey(s)) && gc.opts.isAllowed(append(cmdNames(cmdpath), c.name)) {
+						matches = append(matches, c)
+					}
+				}
+				if len(matches) > 1 {
+					names := make([]string, len(matches))
+					for j, c := range matches {
+						names[j] = c.name
+					}
+					sort.Strings(names)
+					return nil, nil, newParseError(ErrAmbiguousCommand, "ambiguous command: %s (candidates: %s)", s, strings.Join(names, ", "))
+				}
+				if len(matches) == 1 {
+					subcmd, ok = matches[0], true
+				}
+			}
+			if !ok {
+				return nil, nil, newParseError(ErrUnknownCommand, "command not defined: %s (available: %s)", s, strings.Join(available(), ", "))
+			}
+			if !gc.opts.isAllowed(append(cmdNames(cmdpath), subcmd.name)) {
+				return nil, nil, newParseError(ErrCommandNotAvailable, "command not available: %s", s)
+			}
+			subcmd = materialize(subcmd)
+			if gc.opts.ResetFlagValues {
+				if err := ResetFlags(subcmd.fset); err != nil {
+					return nil, nil, err
+				}
 			}
 			cmdpath = append(cmdpath, subcmd)
+			gc.warnDeprecated(subcmd)
 
 			// handle subcommands from a command group
 			if sg, ok := subcmd.cmd.(*groupCmd); ok {
@@ -147,82 +573,579 @@ func (gc *groupCmd) resolve(ctx context.Context, args []string) ([]*cmdData, []s
 			continue
 		}
 
-		// remove the '-' or '--' prefix and '=...' suffix
-		name := s[1:]
-		if s[1] == '-' {
-			name = s[2:]
-		}
-		if len(name) == 0 || name[0] == '-' || name[0] == '=' {
-			return nil, nil, fmt.Errorf("bad flag syntax: %s", s)
-		}
-		value := ""
-		hasValue := strings.Contains(name, "=")
-		if hasValue {
-			pos := strings.Index(name, "=")
-			value = name[pos+1:]
-			name = name[:pos]
+		// decode the flag argument into the flags and their attached values
+		parsed, err := parser.ParseFlag(s, func(name string) (bool, bool) {
+			f, _, ok := lookup(name)
+			if !ok {
+				return false, false
+			}
+			fv, ok := f.Value.(boolFlag)
+			return true, ok && fv.IsBoolFlag()
+		})
+		if err != nil {
+			return nil, nil, gc.flagError(cmdpath, cmdpath[len(cmdpath)-1].fset, err)
 		}
+		short := s[1] != '-'
+		for j, pf := range parsed {
+			name, value, hasValue := pf.Name, pf.Value, pf.HasValue
+
+			// check for the flag in all the parent FlagSets
+			flag, fs, ok := lookup(name)
+			if !ok && gc.opts.AbbreviatedFlags && !(gc.opts.GNUFlags && short) && name != "help" && name != "h" {
+				f, fset, err := lookupPrefix(name)
+				if err != nil {
+					return nil, nil, gc.flagError(cmdpath, cmdpath[len(cmdpath)-1].fset, err)
+				}
+				if f != nil {
+					flag, fs, ok, name = f, fset, true, f.Name
+				}
+			}
+			if !ok {
+				if name == "help" || name == "h" {
+					if !hasValue || wantsHelp(value) {
+						gc.specialCmd = "help"
+					}
+					continue
+				}
+				if _, ok := cmdpath[len(cmdpath)-1].cmd.(*groupCmd); ok {
+					// subcommand flags are only known after the subcommand is selected
+					if owners := gc.flagOwners(cmdNames(cmdpath), cmdpath[len(cmdpath)-1].cmd, name); len(owners) > 0 {
+						return nil, nil, gc.flagError(cmdpath, cmdpath[len(cmdpath)-1].fset, newParseError(ErrUnknownFlag, "flag -%s belongs to '%s'; place it after the command", name, strings.Join(owners, "', '")))
+					}
+				}
+				if alt := suggest(name, flagNames(cmdpath)); len(alt) > 0 {
+					return nil, nil, gc.flagError(cmdpath, cmdpath[len(cmdpath)-1].fset, newParseError(ErrUnknownFlag, "flag provided but not defined: -%s; did you mean -%s?", name, alt))
+				}
+				return nil, nil, gc.flagError(cmdpath, cmdpath[len(cmdpath)-1].fset, newParseError(ErrUnknownFlag, "flag provided but not defined: -%s", name))
+			}
+
+			if gc.opts.RejectShadowedFlags {
+				if err := checkShadowed(cmdpath, name); err != nil {
+					return nil, nil, err
+				}
+			}
 
-		// check for the flag in all the parent FlagSets
-		flag, ok := lookup(name)
-		if !ok {
-			if name == "help" || name == "h" {
-				gc.specialCmd = "help"
+			// handle boolean flag, which doesn't need an argument.
+			if fv, ok := flag.Value.(boolFlag); ok && fv.IsBoolFlag() {
+				if hasValue {
+					if err := fv.Set(value); err != nil {
+						return nil, nil, gc.flagError(cmdpath, fs, newParseError(ErrInvalidFlagValue, "invalid boolean value %q for -%s: %w", value, name, err))
+					}
+				} else {
+					if err := fv.Set("true"); err != nil {
+						return nil, nil, gc.flagError(cmdpath, fs, newParseError(ErrInvalidFlagValue, "invalid boolean flag %s: %w", name, err))
+					}
+				}
+				if err := validateFlag(fs, flag, cmp.Or(value, "true")); err != nil {
+					return nil, nil, gc.flagError(cmdpath, fs, err)
+				}
+				gc.setFlags = append(gc.setFlags, setFlag{flag: flag, name: flag.Name, value: value, hasValue: hasValue})
+				warnDeprecatedFlag(flag, fs)
 				continue
 			}
-			return nil, nil, fmt.Errorf("flag provided but not defined: -%s", name)
+
+			// non-boolean flags must have a value, which might be the next argument.
+			if !hasValue && j == len(parsed)-1 && i+1 < len(args) && !(gc.opts.StrictFlagValues && isDefinedFlag(args[i+1], lookup)) {
+				hasValue = true
+				value = args[i+1]
+				i++
+			}
+			if !hasValue {
+				return nil, nil, gc.flagError(cmdpath, fs, newParseError(ErrFlagNeedsArg, "flag needs an argument: -%s", name))
+			}
+			if err := flag.Value.Set(value); err != nil {
+				return nil, nil, gc.flagError(cmdpath, fs, newParseError(ErrInvalidFlagValue, "invalid value %q for flag -%s: %w", value, name, err))
+			}
+			if err := validateFlag(fs, flag, value); err != nil {
+				return nil, nil, gc.flagError(cmdpath, fs, err)
+			}
+			gc.setFlags = append(gc.setFlags, setFlag{flag: flag, name: flag.Name, value: value, hasValue: true})
+			warnDeprecatedFlag(flag, fs)
 		}
+	}
 
-		// handle boolean flag, which doesn't need an argument.
-		if fv, ok := flag.Value.(boolFlag); ok && fv.IsBoolFlag() {
-			if hasValue {
-				if err := fv.Set(value); err != nil {
-					return nil, nil, fmt.Errorf("invalid boolean value %q for -%s: %w", value, name, err)
+	// select the command path named by the environment variable when no
+	// command is selected at the top-level
+	if len(cmdpath) == 1 && len(gc.specialCmd) == 0 && len(gc.opts.CommandFromEnv) > 0 {
+		for _, word := range strings.Fields(os.Getenv(gc.opts.CommandFromEnv)) {
+			subcmd, ok := cmdDataMap[gc.opts.cmdKey(word)]
+			if !ok {
+				return nil, nil, newParseError(ErrUnknownCommand, "command not defined: %s (from $%s)", word, gc.opts.CommandFromEnv)
+			}
+			if !gc.opts.isAllowed(append(cmdNames(cmdpath), subcmd.name)) {
+				return nil, nil, newParseError(ErrCommandNotAvailable, "command not available: %s", word)
+			}
+			subcmd = materialize(subcmd)
+			if gc.opts.ResetFlagValues {
+				if err := ResetFlags(subcmd.fset); err != nil {
+					return nil, nil, err
 				}
+			}
+			cmdpath = append(cmdpath, subcmd)
+			gc.warnDeprecated(subcmd)
+			if sg, ok := subcmd.cmd.(*groupCmd); ok {
+				prepCmdDataMap(sg.subcmds)
 			} else {
-				if err := fv.Set("true"); err != nil {
-					return nil, nil, fmt.Errorf("invalid boolean flag %s: %w", name, err)
-				}
+				prepCmdDataMap(nil)
 			}
-			continue
 		}
+	}
+
+	// run the default command when no command is selected at the top-level
+	if len(cmdpath) == 1 && len(gc.specialCmd) == 0 && len(gc.opts.DefaultCommand) > 0 {
+		subcmd, ok := cmdDataMap[gc.opts.cmdKey(gc.opts.DefaultCommand)]
+		if !ok || !gc.opts.isAllowed([]string{subcmd.name}) {
+			return nil, nil, newParseError(ErrCommandNotAvailable, "command not available: %s", gc.opts.DefaultCommand)
+		}
+		subcmd = materialize(subcmd)
+		if gc.opts.ResetFlagValues {
+			if err := ResetFlags(subcmd.fset); err != nil {
+				return nil, nil, err
+			}
+		}
+		cmdpath = append(cmdpath, subcmd)
+		gc.warnDeprecated(subcmd)
+		if sg, ok := subcmd.cmd.(*groupCmd); ok {
+			prepCmdDataMap(sg.subcmds)
+		} else {
+			prepCmdDataMap(nil)
+		}
+	}
 
-		// non-boolean flags must have a value, which might be the next argument.
-		if !hasValue && i+1 < len(args) {
-			hasValue = true
-			value = args[i+1]
-			i++
+	rest := args[i:]
+	if _, ok := cmdpath[len(cmdpath)-1].cmd.(*groupCmd); ok && len(gc.specialCmd) == 0 {
+		// groups do not take any arguments other than the subcommands
+		if terminated || len(rest) > 0 {
+			group := filepath.Base(gc.flags.Name())
+			if len(cmdpath) > 1 {
+				group = strings.Join(cmdNames(cmdpath), " ")
+			}
+			// arguments after "--" are never subcommands, so a group cannot be
+			// the last command before it
+			if terminated {
+				return nil, nil, newParseError(ErrUnknownCommand, "no command before \"--\" for command group %q (available: %s)", group, strings.Join(available(), ", "))
+			}
+			return nil, nil, newParseError(ErrUnknownCommand, "unexpected arguments for command group %q: %s (available: %s)", group, strings.Join(rest, " "), strings.Join(available(), ", "))
 		}
-		if !hasValue {
-			return nil, nil, fmt.Errorf("flag needs an argument: -%s", name)
+	}
+	if terminated {
+		gc.terminatedAt = len(positional)
+		if gc.opts.PreserveTerminator {
+			gc.terminatedAt++
 		}
-		if err := flag.Value.Set(value); err != nil {
-			return nil, nil, fmt.Errorf("invalid value %q for flag -%s: %w", value, name, err)
+	}
+	if len(positional) > 0 {
+		rest = append(positional, rest...)
+	}
+	return cmdpath, rest, nil
+}
+
+// warnDeprecated prints a warning to Stderr if the selected command is
+// deprecated, along with its removal version, if any.
+func (gc *groupCmd) warnDeprecated(cd *cmdData) {
+	msg := getDeprecated(cd.cmd)
+	if len(msg) == 0 || gc.opts.deprecationsSilenced() {
+		return
+	}
+	if removeIn := getRemoveIn(cd.cmd); len(removeIn) > 0 {
+		gc.warn("command '%s' is deprecated and will be removed in %s: %s", cd.name, removeIn, msg)
+		return
+	}
+	gc.warn("command '%s' is deprecated: %s", cd.name, msg)
+}
+
+// warn records a warning for the command-line.
+func (gc *groupCmd) warn(format string, args ...any) {
+	gc.warnings = append(gc.warnings, fmt.Sprintf(format, args...))
+}
+
+// printWarnings prints the recorded warnings to Stderr, unless the -quiet
+// flag is set.
+func (gc *groupCmd) printWarnings() {
+	if !gc.quiet {
+		for _, w := range gc.warnings {
+			fmt.Fprintln(gc.opts.stderr(), w)
 		}
 	}
+	gc.warnings = nil
+}
+
+// checkRequired returns an error if a required flag of the flag set is not
+// set in the command-line. Missing flags are prompted for when the
+// interactive prompts are enabled and the standard input is a terminal, unless
+// a missing secret flag cannot be read without the echo.
+func (gc *groupCmd) checkRequired(fset *flag.FlagSet) error {
+	missing := missingFlags(fset, func(f *flag.Flag) bool {
+		return gc.isSet(f) || slices.Contains(gc.configFlags, f)
+	})
+	if len(missing) == 0 {
+		return nil
+	}
+	prompt := gc.opts.InteractivePrompts && isTerminal(gc.opts.stdin())
+	if !canDisableEcho && slices.ContainsFunc(missing, isSecretFlag) {
+		// secret input would be echoed on this platform
+		prompt = false
+	}
+	if !prompt {
+		var names []string
+		for _, f := range missing {
+			names = append(names, "--"+f.Name)
+		}
+		if len(names) == 1 {
+			return newParseError(ErrFlagConstraint, "required flag %s is not set", names[0])
+		}
+		return newParseError(ErrFlagConstraint, "required flags %s are not set", joinWords(names))
+	}
+	for _, f := range missing {
+		value, err := gc.prompt(f)
+		if err != nil {
+			return fmt.Errorf("could not read the value for flag -%s: %w", f.Name, err)
+		}
+		if err := f.Value.Set(value); err != nil {
+			return newParseError(ErrInvalidFlagValue, "invalid value %q for flag -%s: %w", value, f.Name, err)
+		}
+		if err := validateFlag(fset, f, value); err != nil {
+			return err
+		}
+		gc.setFlags = append(gc.setFlags, setFlag{flag: f, name: f.Name, value: value, hasValue: true})
+	}
+	return nil
+}
+
+// prompt asks for the flag value on Stderr and reads it as a line from the
+// standard input. Input is read one byte at a time, so that the rest of the
+// input is left for the command. Input for the secret flags is not echoed.
+func (gc *groupCmd) prompt(f *flag.Flag) (string, error) {
+	_, usage := flag.UnquoteUsage(f)
+	fmt.Fprintf(gc.opts.stderr(), "%s (--%s): ", usage, f.Name)
+	if isSecretFlag(f) {
+		restore := disableEcho(gc.opts.stdin())
+		defer fmt.Fprintln(gc.opts.stderr())
+		defer restore()
+	}
+	return readLine(gc.opts.stdin())
+}
+
+// readLine reads a line from the reader without the line terminator.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err == io.EOF && len(line) > 0 {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
+// flagOwners returns the command paths for the descendants of a group that
+// define the named flag. Parent is the command path to the group.
+func (gc *groupCmd) flagOwners(parent []string, c Command, name string) []string {
+	sg, ok := c.(*groupCmd)
+	if !ok {
+		return nil
+	}
+	var owners []string
+	for _, section := range orderSubcommands(gc.opts, sg.subcmds) {
+		for _, sub := range section {
+			path := append(parent[:len(parent):len(parent)], cmdName(sub))
+			if !gc.opts.isAllowed(path) {
+				continue
+			}
+			if lc, ok := sub.(*lazyCmd); ok {
+				sub = lc.get()
+			}
+			if _, fs, _ := sub.Command(); fs != nil && fs.Lookup(name) != nil {
+				owners = append(owners, strings.Join(path, " "))
+			}
+			owners = append(owners, gc.flagOwners(path, sub, name)...)
+		}
+	}
+	return owners
+}
+
+// flagError records the flag set that defines the flag for a flag parsing
+// error, so that [groupCmd.resolve] can handle the error as per its error
+// handling. Returned error is prefixed with the command path.
+func (gc *groupCmd) flagError(cmdpath []*cmdData, fs *flag.FlagSet, err error) error {
+	gc.errFlags = fs
+	return withCommandPath(cmdNames(cmdpath), err)
+}
+
+// handleFlagError handles a flag parsing error as per the error handling of
+// the flag set that defines the flag, same as the flag.FlagSet.Parse, when the
+// FlagErrorHandling option is set. Errors for the flag.CommandLine are always
+// returned, because its error handling is not chosen by the package users.
+func (gc *groupCmd) handleFlagError(err error) error {
+	fs := gc.errFlags
+	if !gc.opts.FlagErrorHandling || fs == nil || fs == flag.CommandLine {
+		return err
+	}
+	switch fs.ErrorHandling() {
+	case flag.ExitOnError:
+		fmt.Fprintln(gc.opts.stderr(), err)
+		os.Exit(2)
+	case flag.PanicOnError:
+		panic(err)
+	}
+	return err
+}
+
+// isNegativeNumber reports true if the argument is a negative number, like
+// "-123", "-1.5" or "-0x10", which is not also a defined flag name.
+func isNegativeNumber(s string, lookup func(string) (*flag.Flag, *flag.FlagSet, bool)) bool {
+	if len(s) < 2 || s[0] != '-' || (s[1] != '.' && (s[1] < '0' || s[1] > '9')) {
+		return false
+	}
+	if _, _, ok := lookup(s[1:]); ok {
+		return false
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
 
-	return cmdpath, args[i:], nil
+// checkShadowed returns an error if the flag is defined by more than one
+// command in the command path.
+func checkShadowed(cmdpath []*cmdData, name string) error {
+	var owners []string
+	for i, c := range cmdpath {
+		if c.fset.Lookup(name) == nil {
+			continue
+		}
+		owner := filepath.Base(cmdpath[0].fset.Name())
+		if i > 0 {
+			owner = strings.Join(cmdNames(cmdpath[:i+1]), " ")
+		}
+		owners = append(owners, strconv.Quote(owner))
+	}
+	if len(owners) > 1 {
+		return newParseError(ErrShadowedFlag, "flag -%s is defined by multiple commands: %s", name, strings.Join(owners, ", "))
+	}
+	return nil
 }
 
-func (gc *groupCmd) run(ctx context.Context, args []string) error {
-	cmdpath, args, err := gc.resolve(ctx, args)
+func (gc *groupCmd) run(ctx context.Context, args []string) (status error) {
+	if gc.opts.ShowUsageOnError != nil {
+		argv := args
+		defer func() {
+			if status != nil && gc.opts.ShowUsageOnError(status) {
+				// parsing errors already hold the usage for the failed command
+				if usage := ErrorUsage(status); len(usage) > 0 {
+					fmt.Fprint(gc.opts.helpOnError(), usage)
+					return
+				}
+				printHelp(gc.opts.helpOnError(), gc.opts, gc.resolveHelp(argv))
+			}
+		}()
+	}
+
+	cmdpath, rest, err := gc.resolve(ctx, args)
 	if err != nil {
+		gc.path = cmdNames(gc.resolveHelp(args))
 		return err
 	}
+	gc.path, args = cmdNames(cmdpath), rest
 
 	switch gc.specialCmd {
 	case "help":
-		return gc.printHelp(ctx, os.Stdout, cmdpath)
+		if gc.helpAll {
+			return gc.printLeafCommands(gc.opts.stdout(), cmdpath)
+		}
+		return printHelp(gc.opts.stdout(), gc.opts, cmdpath)
 	case "flags":
-		return gc.printFlags(ctx, os.Stdout, cmdpath)
+		return gc.printFlags(ctx, gc.opts.stdout(), cmdpath)
 	case "commands":
-		return gc.printCommands(ctx, os.Stdout, cmdpath)
+		if gc.jsonOutput {
+			return gc.printCommandsJSON(gc.opts.stdout(), cmdpath)
+		}
+		if gc.recursive {
+			return gc.printCommandsAll(gc.opts.stdout(), cmdpath)
+		}
+		return gc.printCommands(ctx, gc.opts.stdout(), cmdpath)
 	}
 
-	fun := cmdpath[len(cmdpath)-1].fun
+	last := cmdpath[len(cmdpath)-1]
+	fun := last.fun
 	if fun == nil {
-		return gc.printHelp(ctx, os.Stdout, cmdpath)
+		// a group is selected without a subcommand, which includes the
+		// top-level for an empty command-line, so the help lists the
+		// subcommands and, at the top-level, the built-in commands
+		return printHelp(gc.opts.helpOnError(), gc.opts, cmdpath)
+	}
+
+	if err := gc.loadConfig(cmdpath); err != nil {
+		return err
+	}
+
+	// flag constraints are checked after the config file is applied, so that
+	// the required flags can be set by the config file
+	for i, c := range cmdpath {
+		if err := checkFlagConstraints(c.fset, gc.isSet); err != nil {
+			return gc.withUsage(cmdpath, withCommandPath(cmdNames(cmdpath[:i+1]), err))
+		}
+	}
+	for i, c := range cmdpath {
+		if err := gc.checkRequired(c.fset); err != nil {
+			return gc.withUsage(cmdpath, withCommandPath(cmdNames(cmdpath[:i+1]), err))
+		}
+	}
+
+	if err := gc.checkArgs(last.cmd, args); err != nil {
+		return gc.withUsage(cmdpath, withCommandPath(cmdNames(cmdpath), err))
+	}
+	if err := gc.createPathParents(cmdpath); err != nil {
+		return err
+	}
+
+	if gc.echo {
+		gc.printEcho(gc.opts.stderr(), cmdpath, args)
+	}
+
+	ctx = context.WithValue(ctx, cmdPathKey{}, cmdNames(cmdpath))
+	if gc.quiet {
+		ctx = context.WithValue(ctx, quietKey{}, true)
+	}
+	if gc.opts.Stdin != nil {
+		ctx = context.WithValue(ctx, stdinKey{}, gc.opts.Stdin)
+	}
+	if gc.opts.ContextDecorator != nil {
+		ctx = gc.opts.ContextDecorator(ctx)
+	}
+	fun = gc.opts.wrap(fun)
+
+	if gc.opts.OnComplete != nil {
+		defer func(path []string, start time.Time) {
+			gc.opts.OnComplete(path, time.Since(start), status)
+		}(cmdNames(cmdpath), time.Now())
+	}
+
+	if timeout := getTimeout(last.cmd, last.fset); timeout > 0 {
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if err := fun(tctx, args); err != nil {
+			if errors.Is(tctx.Err(), context.DeadlineExceeded) {
+				return tctx.Err()
+			}
+			return err
+		}
+		return nil
 	}
 
 	return fun(ctx, args)
 }
+
+// createPathParents creates the parent directories for the path flags with
+// the PathCreateParents check that are set by the command-line, the prompts or
+// the config file. It is called only when the command is about to run.
+func (gc *groupCmd) createPathParents(cmdpath []*cmdData) error {
+	for _, c := range cmdpath {
+		for _, f := range allFlags(c.fset) {
+			v, ok := flagValue(f).(*pathValue)
+			if !ok || (!gc.isSet(f) && !slices.Contains(gc.configFlags, f)) {
+				continue
+			}
+			if err := v.createParents(); err != nil {
+				return fmt.Errorf("could not create the parent directories for flag -%s: %w", f.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkArgs validates the positional arguments for the command. With the
+// StrictTerminatedArgs option, arguments after the "--" that look like flags
+// are also rejected for the commands that validate their arguments.
+func (gc *groupCmd) checkArgs(c Command, args []string) error {
+	// the preserved "--" argument is not a positional argument
+	validated := args
+	if gc.opts.PreserveTerminator && gc.terminatedAt > 0 {
+		validated = slices.Delete(slices.Clone(args), gc.terminatedAt-1, gc.terminatedAt)
+	}
+	if err := validateArgs(c, validated); err != nil {
+		return err
+	}
+	if _, ok := optional(c).(interface{ ValidateArgs([]string) error }); !ok || !gc.opts.StrictTerminatedArgs {
+		return nil
+	}
+	if gc.terminatedAt < 0 || gc.terminatedAt > len(args) {
+		return nil
+	}
+	for _, s := range args[gc.terminatedAt:] {
+		if len(s) > 1 && s[0] == '-' && !isNegativeNumber(s, noFlags) {
+			return newParseError(ErrInvalidArgs, "argument %s after \"--\" looks like a flag; place the flags before \"--\"", s)
+		}
+	}
+	return nil
+}
+
+// noFlags is a flag lookup function that doesn't find any flag.
+func noFlags(string) (*flag.Flag, *flag.FlagSet, bool) {
+	return nil, nil, false
+}
+
+// loadConfig applies the config file named by the config flag, if any.
+func (gc *groupCmd) loadConfig(cmdpath []*cmdData) error {
+	if len(gc.opts.ConfigFlag) == 0 {
+		return nil
+	}
+	f := cmdpath[0].fset.Lookup(gc.opts.ConfigFlag)
+	if f == nil || len(f.Value.String()) == 0 {
+		return nil
+	}
+	config, err := readConfig(f.Value.String())
+	if err != nil {
+		return err
+	}
+	var fsets []*flag.FlagSet
+	for _, c := range cmdpath {
+		fsets = append(fsets, c.fset)
+	}
+	configured, err := applyConfig(fsets, config, gc.isSet)
+	gc.configFlags = configured
+	return err
+}
+
+// printEcho prints the command path, flags and arguments as resolved from the
+// command-line in a normalized form.
+func (gc *groupCmd) printEcho(w io.Writer, cmdpath []*cmdData, args []string) {
+	words := []string{">"}
+	words = append(words, cmdNames(cmdpath)...)
+	for _, f := range gc.setFlags {
+		if f.name == "echo" && gc.opts.EchoFlag {
+			continue
+		}
+		if f.hasValue && isSecretFlag(f.flag) {
+			words = append(words, "--"+f.name+"="+secretMask)
+		} else if f.hasValue {
+			words = append(words, "--"+f.name+"="+quoteWord(f.value))
+		} else {
+			words = append(words, "--"+f.name)
+		}
+	}
+	for _, arg := range args {
+		words = append(words, quoteWord(arg))
+	}
+	fmt.Fprintln(w, strings.Join(words, " "))
+}
+
+// quoteWord quotes the input string if it is empty or contains white space.
+func quoteWord(s string) string {
+	if len(s) == 0 || strings.ContainsAny(s, " \t\n\"'") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -3,13 +3,15 @@
 package cli
 
 import (
-	"context"
 	"flag"
 	"fmt"
 	"io"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"sort"
 	"strings"
+	"text/template"
 )
 
 func numFlags(fs *flag.FlagSet) int {
@@ -19,46 +21,97 @@ func numFlags(fs *flag.FlagSet) int {
 }
 
 func getName(c Command) string {
-	name, _, _ := c.Command()
-	_, file := filepath.Split(name)
+	_, file := filepath.Split(cmdName(c))
 	return file
 }
 
-func getUsage(cmdpath []*cmdData) string {
+// usageData holds the fields for the usage line template; see
+// [Options.UsageTemplate].
+type usageData struct {
+	Command  string
+	HasFlags bool
+	IsGroup  bool
+	Args     string
+}
+
+func getUsage(opts *Options, cmdpath []*cmdData) string {
 	var words []string
 
-	for i, c := range cmdpath {
-		name := c.fset.Name()
-		if i == 0 {
+	for _, c := range cmdpath {
+		name := c.name
+		if len(name) == 0 {
 			_, name = filepath.Split(c.fset.Name())
 		}
 		words = append(words, name)
 	}
+	command := strings.Join(words, " ")
 
+	last := cmdpath[len(cmdpath)-1].cmd
+	if v, ok := optional(last).(interface{ Usage() string }); ok {
+		return strings.TrimSpace(command + " " + v.Usage())
+	}
+
+	data := usageData{Command: command, Args: "<args>"}
 	for _, c := range cmdpath {
 		if n := numFlags(c.fset); n != 0 {
-			words = append(words, "<flags>")
+			data.HasFlags = true
 			break
 		}
 	}
+	_, data.IsGroup = last.(*groupCmd)
+	if v, ok := optional(last).(interface{ ArgNames() []string }); ok {
+		data.Args = formatArgNames(v.ArgNames())
+	}
 
-	if _, ok := cmdpath[len(cmdpath)-1].cmd.(*groupCmd); ok {
+	if len(opts.UsageTemplate) > 0 {
+		if tmpl, err := template.New("usage").Parse(opts.UsageTemplate); err == nil {
+			var sb strings.Builder
+			if err := tmpl.Execute(&sb, data); err == nil {
+				return strings.TrimSpace(sb.String())
+			}
+		}
+	}
+
+	words = []string{data.Command}
+	if data.HasFlags {
+		words = append(words, "<flags>")
+	}
+	if data.IsGroup {
 		words = append(words, "<subcommand>")
 	}
+	if len(data.Args) > 0 {
+		words = append(words, data.Args)
+	}
+	return strings.Join(words, " ")
+}
 
-	words = append(words, "<args>")
+// formatArgNames returns the placeholders for the positional argument names.
+// Names are enclosed in angle brackets unless they are already bracketed, as
+// in "[dst]".
+func formatArgNames(names []string) string {
+	var words []string
+	for _, name := range names {
+		if strings.HasPrefix(name, "<") || strings.HasPrefix(name, "[") {
+			words = append(words, name)
+		} else {
+			words = append(words, "<"+name+">")
+		}
+	}
 	return strings.Join(words, " ")
 }
 
 func getHelpDoc(c Command) string {
-	if v, ok := c.(interface{ Description() string }); ok {
+	if v, ok := optional(c).(interface{ Description() string }); ok {
 		return v.Description()
 	}
+	if v, ok := c.(*groupCmd); ok && len(v.description) > 0 {
+		return v.description
+	}
 	return getPurpose(c)
 }
 
 func getPurpose(c Command) string {
-	if v, ok := c.(interface{ Purpose() string }); ok {
+	if v, ok := optional(c).(interface{ Purpose() string }); ok {
 		return v.Purpose()
 	}
 	if v, ok := c.(*groupCmd); ok {
@@ -67,6 +120,27 @@ func getPurpose(c Command) string {
 	return ""
 }
 
+func getExamples(c Command) []string {
+	if v, ok := optional(c).(interface{ Examples() []string }); ok {
+		return v.Examples()
+	}
+	return nil
+}
+
+func getDeprecated(c Command) string {
+	if v, ok := optional(c).(interface{ Deprecated() string }); ok {
+		return v.Deprecated()
+	}
+	return ""
+}
+
+func getRemoveIn(c Command) string {
+	if v, ok := optional(c).(interface{ RemoveIn() string }); ok {
+		return v.RemoveIn()
+	}
+	return ""
+}
+
 func getFlags(c Command) (*flag.FlagSet, int) {
 	_, fs, _ := c.Command()
 	return fs, numFlags(fs)
@@ -74,111 +148,300 @@ func getFlags(c Command) (*flag.FlagSet, int) {
 
 func getInheritedFlags(cmdpath []*cmdData) (*flag.FlagSet, int) {
 	flagMap := make(map[string][]*flag.Flag)
-	collector := func(f *flag.Flag) {
-		fs := flagMap[f.Name]
-		flagMap[f.Name] = append(fs, f)
-	}
+	ownerMap := make(map[*flag.Flag]*flag.FlagSet)
 	// Collect flag.Flag values defined by ancestors from the command path. A
 	// flag may be defined multiple times unfortunately, in which case, we pick
 	// the closest/deepest flag.Flag to the currently running command.
 	for i := 0; i < len(cmdpath)-1; i++ {
-		cmdpath[i].fset.VisitAll(collector)
+		fs := cmdpath[i].fset
+		fs.VisitAll(func(f *flag.Flag) {
+			flagMap[f.Name] = append(flagMap[f.Name], f)
+			ownerMap[f] = fs
+		})
 	}
+	// Returned flag set copies the flags, which share the aliases and the
+	// other metadata with the owner flag sets.
 	fset := flag.NewFlagSet("temp", flag.ContinueOnError)
 	for _, fs := range flagMap {
-		last := fs[len(fs)-1]
-		fset.Var(last.Value, last.Name, last.Usage)
+		copyFlag(fset, fs[len(fs)-1])
 	}
 	return fset, numFlags(fset)
 }
 
-// getSubcommands returns all subcommand names and purpose as a pair.
-func getSubcommands(cmdpath []*cmdData) [][2]string {
+// hasInheritedFlags reports true if the ancestors from the command path
+// define any flag.
+func hasInheritedFlags(cmdpath []*cmdData) bool {
+	for i := 0; i < len(cmdpath)-1; i++ {
+		if numFlags(cmdpath[i].fset) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// cmdNames returns the command names from the command path, excluding the
+// root.
+func cmdNames(cmdpath []*cmdData) []string {
+	var names []string
+	for _, c := range cmdpath[1:] {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// flagNames returns the names of all flags visible to the last command in the
+// command path, including the inherited flags.
+func flagNames(cmdpath []*cmdData) []string {
+	var names []string
+	for _, c := range cmdpath {
+		c.fset.VisitAll(func(f *flag.Flag) {
+			if !slices.Contains(names, f.Name) {
+				names = append(names, f.Name)
+			}
+		})
+	}
+	return names
+}
+
+// cmdSection is a titled section of subcommands in the help output.
+type cmdSection struct {
+	title string
+	pairs [][2]string
+}
+
+// getCommandGroup returns the help section title for a command, if any.
+func getCommandGroup(c Command) string {
+	if v, ok := optional(c).(interface{ Group() string }); ok {
+		return v.Group()
+	}
+	return ""
+}
+
+// getSubcommands returns all subcommand names and purpose as a pair. Commands
+// rejected by the command filter are skipped. Sections are separated by empty
+// pairs.
+func getSubcommands(opts *Options, cmdpath []*cmdData) [][2]string {
+	var all [][2]string
+	for _, section := range getSubcommandSections(opts, cmdpath) {
+		if len(all) > 0 {
+			all = append(all, [2]string{})
+		}
+		all = append(all, section.pairs...)
+	}
+	return all
+}
+
+// getSubcommandSections returns the subcommand names and purposes split into
+// the help sections. First section holds the built-in commands and the
+// subcommands without a Group() string method. It is followed by a section
+// for every group title in the documentation order.
+func getSubcommandSections(opts *Options, cmdpath []*cmdData) []cmdSection {
 	var spcmds [][2]string
 	if len(cmdpath) == 1 {
-		spcmds = [][2]string{
+		for _, sp := range [][2]string{
 			{"help", "Describe commands and flags"},
 			{"flags", "Describe all known flags"},
 			{"commands", "Lists all command names"},
+		} {
+			if opts.isEnabled(sp[0]) {
+				spcmds = append(spcmds, [2]string{opts.specialName(sp[0]), sp[1]})
+			}
 		}
 	}
 
-	var subcmds, groups [][2]string
+	var all [][2]string
+	if len(spcmds) > 0 {
+		all = append(all, spcmds...)
+	}
+	var titles []string
+	titled := make(map[string][][2]string)
 	if gc, ok := cmdpath[len(cmdpath)-1].cmd.(*groupCmd); ok {
-		for _, c := range gc.subcmds {
-			n, s := getName(c), getPurpose(c)
-			if _, ok := c.(*groupCmd); ok {
-				groups = append(groups, [2]string{n, s})
-			} else {
-				subcmds = append(subcmds, [2]string{n, s})
+		parent := cmdNames(cmdpath)
+		for _, section := range orderSubcommands(opts, gc.subcmds) {
+			var pairs [][2]string
+			for _, c := range section {
+				n, s := getName(c), getPurpose(c)
+				if !opts.isAllowed(append(parent[:len(parent):len(parent)], cmdName(c))) {
+					continue
+				}
+				if len(getDeprecated(c)) > 0 {
+					s = strings.TrimSpace(s + " (deprecated)")
+				}
+				if title := getCommandGroup(c); len(title) > 0 {
+					if _, ok := titled[title]; !ok {
+						titles = append(titles, title)
+					}
+					titled[title] = append(titled[title], [2]string{n, s})
+					continue
+				}
+				pairs = append(pairs, [2]string{n, s})
+			}
+			if len(pairs) > 0 {
+				if len(all) > 0 {
+					all = append(all, [2]string{})
+				}
+				all = append(all, pairs...)
 			}
 		}
 	}
-	sort.SliceStable(subcmds, func(i, j int) bool {
-		return subcmds[i][0] < subcmds[j][0]
-	})
-	sort.SliceStable(groups, func(i, j int) bool {
-		return groups[i][0] < groups[j][0]
-	})
 
-	var all [][2]string
-	if len(spcmds) > 0 {
-		all = append(all, spcmds...)
+	var sections []cmdSection
+	if len(all) > 0 {
+		sections = append(sections, cmdSection{title: "Subcommands", pairs: all})
 	}
-	if len(subcmds) > 0 {
-		if len(all) > 0 {
-			all = append(all, [2]string{})
+	for _, title := range titles {
+		sections = append(sections, cmdSection{title: title, pairs: titled[title]})
+	}
+	return sections
+}
+
+// printCommandList prints command names and purpose pairs as an aligned
+// list. Names are padded to the longest name so that the purposes line up.
+// Empty pairs are printed as blank lines.
+func printCommandList(w io.Writer, pal palette, pairs [][2]string) {
+	width := 15
+	for _, p := range pairs {
+		width = max(width, len(p[0]))
+	}
+	for _, p := range pairs {
+		if len(p[1]) > 0 {
+			pad := strings.Repeat(" ", width-len(p[0]))
+			fmt.Fprintf(w, "\t%s%s  %s\n", pal.name(p[0]), pad, p[1])
+		} else if len(p[0]) > 0 {
+			fmt.Fprintf(w, "\t%s\n", pal.name(p[0]))
+		} else {
+			fmt.Fprintln(w)
 		}
-		all = append(all, subcmds...)
 	}
-	if len(groups) > 0 {
-		if len(all) > 0 {
-			all = append(all, [2]string{})
+}
+
+// orderSubcommands returns the subcommands of a group in the documentation
+// order, split into sections. By default, commands and groups are listed in
+// separate sections, each sorted by the names.
+func orderSubcommands(opts *Options, subcmds []Command) [][]Command {
+	cmds := slices.Clone(subcmds)
+	if !opts.PreserveOrder {
+		sort.SliceStable(cmds, func(i, j int) bool {
+			return getName(cmds[i]) < getName(cmds[j])
+		})
+	}
+	if opts.MergeGroups {
+		return [][]Command{cmds}
+	}
+
+	var plain, groups []Command
+	for _, c := range cmds {
+		if _, ok := c.(*groupCmd); ok {
+			groups = append(groups, c)
+		} else {
+			plain = append(plain, c)
 		}
-		all = append(all, groups...)
 	}
-	return all
+	return [][]Command{plain, groups}
 }
 
-func (gc *groupCmd) printHelp(ctx context.Context, w io.Writer, cmdpath []*cmdData) error {
+// printHelp prints the help text for the last command in the command path.
+func printHelp(w io.Writer, opts *Options, cmdpath []*cmdData) error {
 	last := cmdpath[len(cmdpath)-1]
 
-	usage := getUsage(cmdpath)
+	usage := getUsage(opts, cmdpath)
 	help := getHelpDoc(last.cmd)
-	subcmds := getSubcommands(cmdpath)
-	flags, nflags := getFlags(last.cmd)
-	iflags, niflags := getInheritedFlags(cmdpath)
+	sections := getSubcommandSections(opts, cmdpath)
+	_, nflags := getFlags(last.cmd)
 
-	fmt.Fprintf(w, "Usage: %s\n", usage)
+	pal := opts.palette(w)
+	fmt.Fprintf(w, "%s %s\n", pal.header("Usage:"), usage)
 	if len(help) > 0 {
 		fmt.Fprintln(w)
 		// TODO: Format the help into 80 columns?
 		fmt.Fprintf(w, "%s\n", strings.TrimSpace(help))
 	}
-	if len(subcmds) > 0 {
+	if examples := getExamples(last.cmd); len(examples) > 0 {
 		fmt.Fprintln(w)
-		fmt.Fprintf(w, "Subcommands:\n")
-		for _, sub := range subcmds {
-			if len(sub[1]) > 0 {
-				fmt.Fprintf(w, "\t%-15s  %s\n", sub[0], sub[1])
-			} else if len(sub[0]) > 0 {
-				fmt.Fprintf(w, "\t%-15s\n", sub[0])
-			} else {
-				fmt.Fprintln(w)
+		fmt.Fprintf(w, "%s\n", pal.header("Examples:"))
+		for _, example := range examples {
+			for _, line := range strings.Split(strings.TrimSpace(example), "\n") {
+				fmt.Fprintf(w, "  %s\n", line)
 			}
 		}
 	}
-	if nflags > 0 {
+	for _, section := range sections {
 		fmt.Fprintln(w)
-		fmt.Fprintf(w, "Flags:\n")
-		flags.SetOutput(w)
-		flags.PrintDefaults()
+		fmt.Fprintf(w, "%s\n", pal.header(section.title+":"))
+		printCommandList(w, pal, section.pairs)
 	}
-	if niflags > 0 {
+	if nflags > 0 || hasInheritedFlags(cmdpath) || hasCustomUsage(last.fset) {
 		fmt.Fprintln(w)
-		fmt.Fprintf(w, "Inherited Flags:\n")
-		iflags.SetOutput(w)
-		iflags.PrintDefaults()
+		printFlagSections(w, opts, pal, cmdpath)
 	}
 	return nil
 }
+
+// defaultUsage is the code pointer for the Usage function assigned by
+// flag.NewFlagSet.
+var defaultUsage = reflect.ValueOf(flag.NewFlagSet("", flag.ContinueOnError).Usage).Pointer()
+
+// hasCustomUsage reports true if the flag set has a user-defined Usage
+// function. Usage function of the flag.CommandLine is always considered as the
+// default.
+func hasCustomUsage(fs *flag.FlagSet) bool {
+	if fs == flag.CommandLine || fs.Usage == nil {
+		return false
+	}
+	return reflect.ValueOf(fs.Usage).Pointer() != defaultUsage
+}
+
+// printFlagSections prints the flags and the inherited flags for the last
+// command in the command path under separate sections.
+func printFlagSections(w io.Writer, opts *Options, pal palette, cmdpath []*cmdData) {
+	last := cmdpath[len(cmdpath)-1]
+	flags, nflags := getFlags(last.cmd)
+	iflags, niflags := getInheritedFlags(cmdpath)
+
+	// printed tracks if a section is printed, so that the following sections
+	// are separated by an empty line.
+	printed := false
+	separate := func() {
+		if printed {
+			fmt.Fprintln(w)
+		}
+		printed = true
+	}
+
+	printLocal := func() {
+		if hasCustomUsage(flags) {
+			// Custom usage function takes over the flags section. Output is
+			// restored afterwards, because the flag set may be reused.
+			separate()
+			out := flags.Output()
+			flags.SetOutput(w)
+			flags.Usage()
+			flags.SetOutput(out)
+		} else if groups, categories := groupFlagsByCategory(flags); len(categories) > 0 {
+			for _, category := range categories {
+				separate()
+				fmt.Fprintf(w, "%s\n", pal.header(category+":"))
+				printFlagDefaults(w, pal, flags, groups[category])
+			}
+		} else if nflags > 0 {
+			separate()
+			fmt.Fprintf(w, "%s\n", pal.header(opts.flagsTitle()+":"))
+			printFlagDefaults(w, pal, flags, allFlags(flags))
+		}
+	}
+	printInherited := func() {
+		if niflags > 0 {
+			separate()
+			fmt.Fprintf(w, "%s\n", pal.header(opts.inheritedFlagsTitle()+":"))
+			printFlagDefaults(w, pal, iflags, allFlags(iflags))
+		}
+	}
+
+	if opts.InheritedFlagsFirst {
+		printInherited()
+		printLocal()
+	} else {
+		printLocal()
+		printInherited()
+	}
+}
@@ -0,0 +1,634 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPrintFlagsInherited(t *testing.T) {
+	ctx := context.Background()
+
+	gflags := flag.NewFlagSet("global", flag.ContinueOnError)
+	gflags.Bool("verbose", false, "enable verbose output")
+
+	start := newTestCmd("start")
+	start.flags.Int("port", 8080, "server port")
+	server := NewGroup("server", "manage server", start)
+
+	var stdout strings.Builder
+	opts := &Options{GlobalFlags: gflags, Stdout: &stdout}
+	if err := opts.Run(ctx, []Command{server}, []string{"flags", "server", "start"}); err != nil {
+		t.Fatal(err)
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, "Flags:\n  --port int\n") {
+		t.Fatalf("want own flags first, got %q", out)
+	}
+	if !strings.Contains(out, "\nInherited Flags:\n") || !strings.Contains(out, "-verbose") {
+		t.Fatalf("want inherited flags section, got %q", out)
+	}
+}
+
+func TestFlagSectionTitles(t *testing.T) {
+	ctx := context.Background()
+
+	gflags := flag.NewFlagSet("global", flag.ContinueOnError)
+	gflags.Bool("verbose", false, "enable verbose output")
+
+	start := newTestCmd("start")
+	start.flags.Int("port", 8080, "server port")
+	server := NewGroup("server", "manage server", start)
+
+	var stdout strings.Builder
+	opts := &Options{
+		GlobalFlags:         gflags,
+		Stdout:              &stdout,
+		FlagsTitle:          "Command Options",
+		InheritedFlagsTitle: "Global Options",
+		InheritedFlagsFirst: true,
+	}
+	if err := opts.Run(ctx, []Command{server}, []string{"flags", "server", "start"}); err != nil {
+		t.Fatal(err)
+	}
+	out := stdout.String()
+	if !strings.HasPrefix(out, "Global Options:\n") {
+		t.Fatalf("want global options first, got %q", out)
+	}
+	if !strings.HasSuffix(out, "\n\nCommand Options:\n  --port int\n    \tserver port (default 8080)\n") {
+		t.Fatalf("want command options last, got %q", out)
+	}
+}
+
+func TestCustomFlagUsage(t *testing.T) {
+	ctx := context.Background()
+
+	start := newTestCmd("start")
+	start.flags.Int("port", 8080, "server port")
+	start.flags.Usage = func() {
+		fmt.Fprintln(start.flags.Output(), "Custom usage for start")
+	}
+	server := NewGroup("server", "manage server", start)
+
+	var saved strings.Builder
+	start.flags.SetOutput(&saved)
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout}
+	if err := opts.Run(ctx, []Command{server}, []string{"help", "server", "start"}); err != nil {
+		t.Fatal(err)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "\nCustom usage for start\n") {
+		t.Fatalf("want custom usage, got %q", out)
+	}
+	if strings.Contains(out, "-port") {
+		t.Fatalf("want custom usage to replace the flags section, got %q", out)
+	}
+	if start.flags.Output() != &saved {
+		t.Fatalf("want flag set output to be restored")
+	}
+}
+
+func TestHelpCommandPath(t *testing.T) {
+	ctx := context.Background()
+
+	start := newTestCmd("start")
+	start.flags.Int("port", 8080, "server port")
+	stop := newTestCmd("stop")
+	server := NewGroup("server", "Server operations", start, stop)
+	cmds := []Command{server}
+
+	for _, args := range [][]string{
+		{"help", "server", "start"},
+		{"server", "help", "start"},
+		{"server", "start", "-h"},
+		{"server", "start", "--help=true"},
+		{"server", "-h=1", "start"},
+	} {
+		var stdout strings.Builder
+		opts := &Options{Stdout: &stdout}
+		if err := opts.Run(ctx, cmds, args); err != nil {
+			t.Fatal(err)
+		}
+		if out := stdout.String(); !strings.HasPrefix(out, "Usage: ") || !strings.Contains(out, " server start <flags> <args>\n") {
+			t.Errorf("Run(%q): want help for server start, got %q", args, out)
+		}
+		if start.args != nil {
+			t.Errorf("Run(%q): want start command not to run", args)
+		}
+	}
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout}
+	if err := opts.Run(ctx, cmds, []string{"help", "server"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := stdout.String(); !strings.Contains(out, "Server operations") || !strings.Contains(out, "\tstart") {
+		t.Errorf("want help for server group, got %q", out)
+	}
+
+	// A false value for the help flag runs the command.
+	if err := Run(ctx, cmds, []string{"server", "start", "-help=false", "now"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(start.args, []string{"now"}) {
+		t.Errorf("want start command to run, got args %q", start.args)
+	}
+}
+
+func TestSubcommandOrder(t *testing.T) {
+	status := newTestCmd("status")
+	add := newTestCmd("add")
+	remote := NewGroup("remote", "Manage remotes")
+	branch := NewGroup("branch", "Manage branches")
+	root := &groupCmd{subcmds: []Command{status, remote, add, branch}}
+	cmdpath := []*cmdData{{name: "root", cmd: root}, {name: "git", cmd: root}}
+
+	names := func(opts *Options) []string {
+		var names []string
+		for _, sub := range getSubcommands(opts, cmdpath) {
+			names = append(names, sub[0])
+		}
+		return names
+	}
+
+	tests := []struct {
+		opts *Options
+		want []string
+	}{
+		{&Options{}, []string{"add", "status", "", "branch", "remote"}},
+		{&Options{PreserveOrder: true}, []string{"status", "add", "", "remote", "branch"}},
+		{&Options{MergeGroups: true}, []string{"add", "branch", "remote", "status"}},
+		{&Options{PreserveOrder: true, MergeGroups: true}, []string{"status", "remote", "add", "branch"}},
+	}
+	for _, tt := range tests {
+		if got := names(tt.opts); !slices.Equal(got, tt.want) {
+			t.Errorf("getSubcommands(%+v): got %q, want %q", *tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestCommandListAlignment(t *testing.T) {
+	var sb strings.Builder
+	printCommandList(&sb, palette{}, [][2]string{
+		{"get", "Get a value"},
+		{"reindex-database", "Rebuild all database indexes"},
+		{},
+		{"version", ""},
+	})
+	want := "" +
+		"\tget               Get a value\n" +
+		"\treindex-database  Rebuild all database indexes\n" +
+		"\n" +
+		"\tversion\n"
+	if got := sb.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestPrintFlagDefaults(t *testing.T) {
+	fset := flag.NewFlagSet("test", flag.ContinueOnError)
+	fset.Int("port", 8080, "server port")
+	fset.String("host", "localhost", "server host")
+	fset.String("user", "", "user `name` for login")
+	fset.Bool("v", false, "verbose output")
+	fset.Duration("timeout", 0, "request timeout")
+	var headers []string
+	StringSliceVar(fset, &headers, "header", "request header")
+
+	var sb strings.Builder
+	printFlagDefaults(&sb, palette{}, fset, allFlags(fset))
+	want := "" +
+		"  --header strings\n    \trequest header\n" +
+		"  --host string\n    \tserver host (default \"localhost\")\n" +
+		"  --port int\n    \tserver port (default 8080)\n" +
+		"  --timeout duration\n    \trequest timeout\n" +
+		"  --user name\n    \tuser name for login\n" +
+		"  -v\n    \tverbose output\n"
+	if got := sb.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+// rangeValue is a composite flag value with a custom default text.
+type rangeValue struct{ lo, hi int }
+
+func (v *rangeValue) String() string { return fmt.Sprintf("{%d %d}", v.lo, v.hi) }
+
+func (v *rangeValue) Set(s string) error {
+	_, err := fmt.Sscanf(s, "%d-%d", &v.lo, &v.hi)
+	return err
+}
+
+func (v *rangeValue) DefaultText() string {
+	if v.lo == 0 && v.hi == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d-%d", v.lo, v.hi)
+}
+
+func TestFlagDefaultText(t *testing.T) {
+	fset := flag.NewFlagSet("test", flag.ContinueOnError)
+	fset.Var(&rangeValue{lo: 1, hi: 10}, "pages", "page `range`")
+	fset.Var(new(rangeValue), "rows", "row `range`")
+
+	var sb strings.Builder
+	printFlagDefaults(&sb, palette{}, fset, allFlags(fset))
+	want := "" +
+		"  --pages range\n    \tpage range (default 1-10)\n" +
+		"  --rows range\n    \trow range\n"
+	if got := sb.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestSetDefaultDisplay(t *testing.T) {
+	ctx := context.Background()
+
+	global := flag.NewFlagSet("global", flag.ContinueOnError)
+	config := global.String("config", "/home/user/.myapp/config", "config `file`")
+	global.String("cache", "/home/user/.cache", "cache directory")
+	if err := SetDefaultDisplay(global, "config", "~/.myapp/config"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetDefaultDisplay(global, "cache", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetDefaultDisplay(global, "undefined", "x"); err == nil {
+		t.Fatalf("want error for undefined flag")
+	}
+
+	var sb strings.Builder
+	printFlagDefaults(&sb, palette{}, global, allFlags(global))
+	want := "" +
+		"  --cache string\n    \tcache directory\n" +
+		"  --config file\n    \tconfig file (default ~/.myapp/config)\n"
+	if got := sb.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+	if *config != "/home/user/.myapp/config" {
+		t.Fatalf("want actual default unchanged, got %q", *config)
+	}
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout, GlobalFlags: global, IsolateCommandLine: true}
+	if err := opts.Run(ctx, []Command{newTestCmd("run")}, []string{"help", "run"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "config file (default ~/.myapp/config)\n") {
+		t.Fatalf("want display default for inherited flag, got %q", stdout.String())
+	}
+
+	// Display text set after a run is found through the inherited flags.
+	if err := SetDefaultDisplay(global, "cache", "~/.cache"); err != nil {
+		t.Fatal(err)
+	}
+	run := newTestCmd("run")
+	sb.Reset()
+	printFlagSections(&sb, new(Options), palette{}, []*cmdData{{cmd: &groupCmd{}, fset: global}, {name: "run", cmd: run, fset: run.flags}})
+	if !strings.Contains(sb.String(), "cache directory (default ~/.cache)\n") {
+		t.Fatalf("want updated display default for inherited flag, got %q", sb.String())
+	}
+}
+
+func TestFlagCategories(t *testing.T) {
+	fset := flag.NewFlagSet("list", flag.ContinueOnError)
+	fset.Int("connect-port", 10000, "api port")
+	fset.String("connect-host", "", "api host")
+	fset.String("format", "", "output format")
+	fset.Bool("descend", false, "descending order")
+	for _, name := range []string{"connect-port", "connect-host"} {
+		if err := SetFlagCategory(fset, name, "Connection"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SetFlagCategory(fset, "format", "Output"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFlagCategory(fset, "undefined", "Output"); err == nil {
+		t.Fatalf("want error for undefined flag")
+	}
+
+	list := NewCommand("list", nil, fset, "List items")
+	var sb strings.Builder
+	printFlagSections(&sb, new(Options), palette{}, []*cmdData{{cmd: &groupCmd{}, fset: flag.NewFlagSet("root", flag.ContinueOnError)}, {name: "list", cmd: list, fset: fset}})
+	want := "" +
+		"Connection:\n" +
+		"  --connect-host string\n    \tapi host\n" +
+		"  --connect-port int\n    \tapi port (default 10000)\n" +
+		"\n" +
+		"Options:\n" +
+		"  --descend\n    \tdescending order\n" +
+		"\n" +
+		"Output:\n" +
+		"  --format string\n    \toutput format\n"
+	if got := sb.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestHelpWithInvalidFlags(t *testing.T) {
+	ctx := context.Background()
+
+	start := newTestCmd("start")
+	server := NewGroup("server", "Server operations", start)
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout}
+	if err := opts.Run(ctx, []Command{server}, []string{"server", "--badflag", "start", "-h"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := stdout.String(); !strings.Contains(out, " server start <flags> <args>\n") {
+		t.Fatalf("want help for server start, got %q", out)
+	}
+	stdout.Reset()
+	if err := opts.Run(ctx, []Command{server}, []string{"server", "--badflag", "start", "--help=yes"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := stdout.String(); !strings.Contains(out, " server start <flags> <args>\n") {
+		t.Fatalf("want help for server start with --help=yes, got %q", out)
+	}
+
+	// Help flag after "--" is an argument.
+	if err := opts.Run(ctx, []Command{server}, []string{"server", "--badflag", "--", "-h"}); !errors.Is(err, ErrUnknownFlag) {
+		t.Fatalf("want ErrUnknownFlag, got %v", err)
+	}
+}
+
+func TestColorHelp(t *testing.T) {
+	ctx := context.Background()
+
+	// Clear the color variables; t.Setenv restores them after the test.
+	t.Setenv("NO_COLOR", "")
+	os.Unsetenv("NO_COLOR")
+	t.Setenv("CLICOLOR_FORCE", "")
+
+	start := newTestCmd("start")
+	start.flags.Int("port", 8080, "server port")
+	server := NewGroup("server", "manage server", start)
+
+	run := func(color ColorMode) string {
+		var stdout strings.Builder
+		opts := &Options{Stdout: &stdout, Color: color}
+		if err := opts.Run(ctx, []Command{server}, []string{"help", "server"}); err != nil {
+			t.Fatal(err)
+		}
+		return stdout.String()
+	}
+
+	plain := run(ColorNever)
+	if auto := run(ColorAuto); auto != plain {
+		t.Fatalf("want no colors for non-terminal writers, got %q", auto)
+	}
+	if strings.Contains(plain, "\x1b[") {
+		t.Fatalf("want no escape codes, got %q", plain)
+	}
+
+	colored := run(ColorAlways)
+	for _, want := range []string{
+		ansiBold + "Usage:" + ansiReset,
+		ansiBold + "Subcommands:" + ansiReset,
+		ansiCyan + "start" + ansiReset,
+	} {
+		if !strings.Contains(colored, want) {
+			t.Fatalf("want %q in the colored output, got %q", want, colored)
+		}
+	}
+	stripped := strings.NewReplacer(ansiBold, "", ansiCyan, "", ansiReset, "").Replace(colored)
+	if stripped != plain {
+		t.Fatalf("want colors to preserve the layout, got %q, want %q", stripped, plain)
+	}
+
+	var sb strings.Builder
+	fset := flag.NewFlagSet("test", flag.ContinueOnError)
+	fset.Int("port", 8080, "server port")
+	printFlagDefaults(&sb, palette{enabled: true}, fset, allFlags(fset))
+	if !strings.HasPrefix(sb.String(), "  "+ansiCyan+"--port"+ansiReset+" int\n") {
+		t.Fatalf("want colored flag name, got %q", sb.String())
+	}
+}
+
+type usageCmd struct {
+	*TestCmd
+}
+
+func (c *usageCmd) Usage() string {
+	return "[options] <file>..."
+}
+
+func TestUsageLine(t *testing.T) {
+	ctx := context.Background()
+
+	start := newTestCmd("start")
+	start.flags.Int("port", 8080, "server port")
+	cat := &usageCmd{newTestCmd("cat")}
+	server := NewGroup("server", "Server operations", start, cat)
+	cmds := []Command{server}
+
+	stdout, _, err := RunCapture(ctx, cmds, []string{"help", "server", "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, " server cat [options] <file>...\n") {
+		t.Fatalf("want custom usage line, got %q", stdout)
+	}
+
+	var sb strings.Builder
+	opts := &Options{
+		Stdout:        &sb,
+		UsageTemplate: "{{.Command}}{{if .HasFlags}} [options]{{end}}{{if .IsGroup}} COMMAND{{end}} {{.Args}}",
+	}
+	if err := opts.Run(ctx, cmds, []string{"help", "server", "start"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), " server start [options] <args>\n") {
+		t.Fatalf("want usage line from the template, got %q", sb.String())
+	}
+
+	bad := &Options{UsageTemplate: "{{.Command"}
+	if err := bad.Run(ctx, cmds, []string{"help"}); err == nil {
+		t.Fatalf("want error for invalid usage template")
+	}
+}
+
+type copyCmd struct {
+	*TestCmd
+	names []string
+}
+
+func (c *copyCmd) ArgNames() []string {
+	return c.names
+}
+
+func TestArgNames(t *testing.T) {
+	ctx := context.Background()
+
+	cp := &copyCmd{newTestCmd("copy"), []string{"src", "[dst]"}}
+	sync := &copyCmd{newTestCmd("sync"), []string{}}
+	cmds := []Command{cp, sync}
+
+	stdout, _, err := RunCapture(ctx, cmds, []string{"help", "copy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, " copy <flags> <src> [dst]\n") {
+		t.Fatalf("want argument names in usage, got %q", stdout)
+	}
+
+	stdout, _, err = RunCapture(ctx, cmds, []string{"help", "sync"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, " sync <flags>\n") {
+		t.Fatalf("want no argument placeholder in usage, got %q", stdout)
+	}
+
+	if info := Info(cp); info.Usage != "copy <src> [dst]" {
+		t.Fatalf("want argument names in info, got %q", info.Usage)
+	}
+}
+
+type validatedCmd struct {
+	*TestCmd
+	validate func([]string) error
+}
+
+func (c *validatedCmd) ValidateArgs(args []string) error {
+	return c.validate(args)
+}
+
+type noArgNamesCmd struct {
+	*noArgsCmd
+}
+
+func (c *noArgNamesCmd) ArgNames() []string { return nil }
+
+func TestUsageWithValidateArgs(t *testing.T) {
+	called := false
+	cmd := &validatedCmd{newTestCmd("list"), func(args []string) error {
+		called = true
+		return NoArgs(args)
+	}}
+	if got := Info(cmd).Usage; got != "list <args>" || called {
+		t.Fatalf("want generic usage without calling the validator, got %q and %v", got, called)
+	}
+
+	// A nil ArgNames documents that the command takes no arguments.
+	list := &noArgNamesCmd{&noArgsCmd{newTestCmd("list")}}
+	if got := Info(list).Usage; got != "list" {
+		t.Fatalf("want usage without arguments, got %q", got)
+	}
+	stdout, _, err := RunCapture(context.Background(), []Command{list}, []string{"list", "extra"})
+	if !errors.Is(err, ErrInvalidArgs) || !strings.Contains(ErrorUsage(err), " list <flags>\n") {
+		t.Fatalf("want NoArgs error with the usage without arguments, got %v, %q and %q", err, ErrorUsage(err), stdout)
+	}
+}
+
+type sectionCmd struct {
+	*TestCmd
+	group string
+}
+
+func (c *sectionCmd) Group() string {
+	return c.group
+}
+
+func TestCommandSections(t *testing.T) {
+	ctx := context.Background()
+
+	get := &sectionCmd{newTestCmd("get"), "Basic Commands"}
+	set := &sectionCmd{newTestCmd("set"), "Basic Commands"}
+	repair := &sectionCmd{newTestCmd("repair"), "Advanced Commands"}
+	version := newTestCmd("version")
+	cmds := []Command{repair, set, get, version}
+
+	stdout, _, err := RunCapture(ctx, cmds, []string{"help"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\nSubcommands:\n" +
+		"\thelp             Describe commands and flags\n" +
+		"\tflags            Describe all known flags\n" +
+		"\tcommands         Lists all command names\n" +
+		"\n" +
+		"\tversion\n" +
+		"\n" +
+		"Basic Commands:\n" +
+		"\tget\n" +
+		"\tset\n" +
+		"\n" +
+		"Advanced Commands:\n" +
+		"\trepair\n"
+	if !strings.Contains(stdout, want) {
+		t.Fatalf("want command sections %q, got %q", want, stdout)
+	}
+}
+
+func TestRootHelp(t *testing.T) {
+	ctx := context.Background()
+
+	version := newTestCmd("version")
+	cmds := []Command{version}
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout, Purpose: "Manage the widgets"}
+	if err := opts.Run(ctx, cmds, []string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "\n\nManage the widgets\n\nSubcommands:\n") {
+		t.Fatalf("want program purpose in help, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	opts.Description = "Widgets tool manages the widgets.\n\nIt is a long description."
+	if err := opts.Run(ctx, cmds, []string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := stdout.String(); !strings.Contains(out, "\n\nWidgets tool manages the widgets.\n\nIt is a long description.\n\nSubcommands:\n") {
+		t.Fatalf("want program description in help, got %q", out)
+	}
+}
+
+func TestHelpExamples(t *testing.T) {
+	ctx := context.Background()
+
+	cp := &describedCmd{newTestCmd("copy")}
+	var sb strings.Builder
+	opts := &Options{Stdout: &sb}
+	if err := opts.Run(ctx, []Command{cp}, []string{"help", "copy"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Copies files from src to dst.\n\nExamples:\n  copy a.txt b.txt\n  copy -force \\\n    a.txt b.txt\n\n"; !strings.Contains(sb.String(), want) {
+		t.Fatalf("want examples after the description, got %q", sb.String())
+	}
+}
+
+func TestRootHelpWithoutArgs(t *testing.T) {
+	ctx := context.Background()
+
+	cmds := []Command{newTestCmd("version"), NewGroup("db", "manage database", newTestCmd("scan"))}
+	for _, args := range [][]string{nil, {}} {
+		var stdout strings.Builder
+		opts := &Options{Stdout: &stdout, IsolateCommandLine: true}
+		if err := opts.Run(ctx, cmds, args); err != nil {
+			t.Fatal(err)
+		}
+		want := "\n\nSubcommands:\n" +
+			"\thelp             Describe commands and flags\n" +
+			"\tflags            Describe all known flags\n" +
+			"\tcommands         Lists all command names\n" +
+			"\n\tversion\n" +
+			"\n\tdb               manage database\n"
+		if out := stdout.String(); !strings.HasPrefix(out, "Usage: ") || !strings.HasSuffix(out, want) {
+			t.Fatalf("want root help for %q, got %q", args, out)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import "flag"
+
+// CommandInfo describes a command for the programmatic use, like building
+// alternative front-ends.
+type CommandInfo struct {
+	// Name is the command name.
+	Name string
+
+	// Usage is the one line usage summary for the command, relative to the
+	// command itself.
+	Usage string
+
+	// Purpose and Description are the short and long documentation for the
+	// command, if any.
+	Purpose, Description string
+
+	// Examples holds the usage examples for the command, if any.
+	Examples []string
+
+	// Flags describes the flags defined by the command in lexicographical
+	// order.
+	Flags []FlagInfo
+
+	// Subcommands holds the subcommand names in the documentation order, if
+	// the command is a group.
+	Subcommands []string
+}
+
+// FlagInfo describes a flag.
+type FlagInfo struct {
+	// Name is the flag name without the dash prefix.
+	Name string
+
+	// Usage is the help message for the flag.
+	Usage string
+
+	// Default is the default value for the flag in text form. Help output
+	// uses the DefaultText() string method of the flag.Value instead, when
+	// implemented.
+	Default string
+
+	// Placeholder is a name for the flag value; empty for boolean flags.
+	Placeholder string
+
+	// Options holds the allowed values for the flag, if the flag.Value
+	// implements the Options() []string method, as for the enum flags. They
+	// can be offered as the candidates when completing the flag value.
+	Options []string
+}
+
+// Info returns the documentation details for a command. Lazy commands are
+// constructed as necessary.
+//
+// Example:
+//
+//	info := cli.Info(cmd)
+//	fmt.Println(info.Usage)
+//	for _, f := range info.Flags {
+//	    fmt.Printf("-%s: %s\n", f.Name, f.Usage)
+//	}
+func Info(cmd Command) CommandInfo {
+	c := cmd
+	if lc, ok := cmd.(*lazyCmd); ok {
+		c = lc.get()
+	}
+	name := cmdName(cmd)
+	_, fset, fun := c.Command()
+	cd := &cmdData{name: name, fset: fset, fun: fun, cmd: c}
+
+	info := CommandInfo{
+		Name:    name,
+		Usage:   getUsage(new(Options), []*cmdData{cd}),
+		Purpose: getPurpose(cmd),
+	}
+	if v, ok := optional(c).(interface{ Description() string }); ok {
+		info.Description = v.Description()
+	}
+	info.Examples = getExamples(c)
+	for _, f := range allFlags(fset) {
+		placeholder, usage := flagPlaceholder(f)
+		info.Flags = append(info.Flags, FlagInfo{
+			Name:        f.Name,
+			Usage:       usage,
+			Default:     f.DefValue,
+			Placeholder: placeholder,
+			Options:     flagOptions(f),
+		})
+	}
+	if gc, ok := c.(*groupCmd); ok {
+		for _, section := range orderSubcommands(new(Options), gc.subcmds) {
+			for _, sub := range section {
+				info.Subcommands = append(info.Subcommands, cmdName(sub))
+			}
+		}
+	}
+	return info
+}
+
+// flagOptions returns the allowed values for a flag whose value implements the
+// Options() []string method.
+func flagOptions(f *flag.Flag) []string {
+	if v, ok := flagValue(f).(interface{ Options() []string }); ok {
+		return v.Options()
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+type describedCmd struct {
+	*TestCmd
+}
+
+func (d *describedCmd) Purpose() string     { return "Copy files" }
+func (d *describedCmd) Description() string { return "Copies files from src to dst." }
+func (d *describedCmd) Examples() []string {
+	return []string{"copy a.txt b.txt", "copy -force \\\n  a.txt b.txt"}
+}
+
+func TestInfo(t *testing.T) {
+	cp := &describedCmd{newTestCmd("copy")}
+	cp.flags.Bool("force", false, "overwrite existing files")
+	cp.flags.Int("retries", 3, "number of retries")
+
+	got := Info(cp)
+	want := CommandInfo{
+		Name:        "copy",
+		Usage:       "copy <flags> <args>",
+		Purpose:     "Copy files",
+		Description: "Copies files from src to dst.",
+		Examples:    []string{"copy a.txt b.txt", "copy -force \\\n  a.txt b.txt"},
+		Flags: []FlagInfo{
+			{Name: "force", Usage: "overwrite existing files", Default: "false"},
+			{Name: "retries", Usage: "number of retries", Default: "3", Placeholder: "int"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Info: got %+v, want %+v", got, want)
+	}
+
+	noop := func(context.Context, []string) error { return nil }
+	stop := NewCommand("stop", noop, nil, "Stop server")
+	start := NewCommand("start", noop, flag.NewFlagSet("", flag.ContinueOnError), "Start server")
+	server := NewGroup("server", "Server operations", stop, start, NewGroup("config", "Configuration"))
+
+	got = Info(server)
+	want = CommandInfo{
+		Name:        "server",
+		Usage:       "server <subcommand> <args>",
+		Purpose:     "Server operations",
+		Subcommands: []string{"start", "stop", "config"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Info: got %+v, want %+v", got, want)
+	}
+}
+
+type formatValue string
+
+func (v *formatValue) String() string { return string(*v) }
+
+func (v *formatValue) Set(s string) error {
+	if !slices.Contains(v.Options(), s) {
+		return fmt.Errorf("must be one of %q", v.Options())
+	}
+	*v = formatValue(s)
+	return nil
+}
+
+func (v *formatValue) Options() []string { return []string{"json", "text", "yaml"} }
+
+func TestInfoFlagOptions(t *testing.T) {
+	show := newTestCmd("show")
+	format := formatValue("text")
+	show.flags.Var(&format, "format", "output format")
+	show.flags.Bool("all", false, "show all")
+	// metadata does not hide the options
+	if err := SetFlagCategory(show.flags, "format", "Output"); err != nil {
+		t.Fatal(err)
+	}
+
+	info := Info(show)
+	if len(info.Flags) != 2 || info.Flags[0].Options != nil {
+		t.Fatalf("want no options for the ordinary flags, got %+v", info.Flags)
+	}
+	if got := info.Flags[1].Options; !slices.Equal(got, []string{"json", "text", "yaml"}) {
+		t.Fatalf("want allowed values as the options, got %q", got)
+	}
+}
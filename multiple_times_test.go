@@ -4,8 +4,11 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"io"
 	"log"
+	"os"
 	"testing"
 )
 
@@ -49,3 +52,116 @@ func TestMultipleRuns(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestResetFlags(t *testing.T) {
+	ctx := context.Background()
+
+	var force bool
+	tags := []string{"a", "b"}
+	var verbosity int
+	fset := new(flag.FlagSet)
+	fset.BoolVar(&force, "force", false, "force the operation")
+	StringSliceVar(fset, &tags, "tag", "tags for the operation")
+	CountVar(fset, &verbosity, "v", "increase verbosity")
+	cmd := NewCommand("apply", func(context.Context, []string) error { return nil }, fset, "Apply changes")
+
+	if err := Run(ctx, []Command{cmd}, []string{"apply", "-force", "-tag=x", "-v", "-v"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(ctx, []Command{cmd}, []string{"apply"}); err != nil {
+		t.Fatal(err)
+	}
+	if !force {
+		t.Fatalf("want flag values to leak by default")
+	}
+
+	opts := &Options{ResetFlagValues: true}
+	if err := opts.Run(ctx, []Command{cmd}, []string{"apply", "-tag=y"}); err != nil {
+		t.Fatal(err)
+	}
+	if force || verbosity != 0 || len(tags) != 1 || tags[0] != "y" {
+		t.Fatalf("want flags reset before parsing, got %v, %d and %q", force, verbosity, tags)
+	}
+
+	if err := ResetFlags(fset); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Fatalf("want default tags, got %q", tags)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+
+	var port int
+	var got []int
+	fset := new(flag.FlagSet)
+	fset.IntVar(&port, "port", 10000, "TCP Port number")
+	cmd := NewCommand("serve", func(ctx context.Context, args []string) error {
+		got = append(got, port)
+		return nil
+	}, fset, "Serve requests")
+
+	d, err := NewDispatcher(nil, []Command{cmd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"serve", "-port", "9999"}, {"serve"}} {
+		if err := d.Run(ctx, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 2 || got[0] != 9999 || got[1] != 10000 {
+		t.Fatalf("want flags reset between runs, got %v", got)
+	}
+	if err := d.Run(ctx, []string{"serve", "-bad"}); !errors.Is(err, ErrUnknownFlag) {
+		t.Fatalf("want ErrUnknownFlag, got %v", err)
+	}
+	if err := d.Run(ctx, []string{"serve"}); err != nil {
+		t.Fatalf("want a clean run after an error, got %v", err)
+	}
+
+	dup := NewGroup("db", "Database commands", cmd, NewCommand("serve", func(context.Context, []string) error { return nil }, nil, "Serve"))
+	if _, err := NewDispatcher(nil, []Command{dup}); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("want os.ErrExist for a duplicate name, got %v", err)
+	}
+}
+
+func TestDispatcherResolve(t *testing.T) {
+	ctx := context.Background()
+
+	var ran bool
+	cmd := NewCommand("serve", func(context.Context, []string) error {
+		ran = true
+		return nil
+	}, nil, "Serve requests")
+	d, err := NewDispatcher(&Options{Stdout: io.Discard}, []Command{cmd})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{{"help", "serve"}, {"serve", "-h"}, {"flags"}, {"commands"}} {
+		_, _, special, err := d.Resolve(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(special) == 0 {
+			t.Fatalf("want a built-in command for %q", args)
+		}
+		if err := d.Run(ctx, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ran {
+		t.Fatalf("want serve command not to be executed")
+	}
+
+	path, _, special, err := d.Resolve([]string{"serve"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(special) != 0 || len(path) != 1 || path[0] != "serve" {
+		t.Fatalf("got path %q, special %q", path, special)
+	}
+}
@@ -0,0 +1,630 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+)
+
+// Options customizes the behavior of the CLI. A zero Options value is valid
+// and behaves the same as the package level [Run] function.
+//
+// Example:
+//
+//	opts := &cli.Options{
+//	    CommandFilter: func(path []string) bool {
+//	        return isAdmin || path[0] != "admin"
+//	    },
+//	}
+//	err := opts.Run(context.Background(), cmds, os.Args)
+type Options struct {
+	// CommandFilter, when non-nil, is consulted for every command that is
+	// resolved or listed. The path argument holds the full command path from
+	// the top-level command to the command being checked. Commands for which
+	// the filter returns false are hidden from the documentation and fail to
+	// resolve with a "command not available" error.
+	CommandFilter func(path []string) bool
+
+	// GlobalFlags, when non-nil, holds flags that are defined at the top-level
+	// and are inherited by all commands. These flags are accepted anywhere in
+	// the command-line and are documented as inherited flags by the
+	// subcommands. Flag metadata, like the aliases, the required flags and
+	// the constraints, is set on this flag set, as for any other flag set.
+	GlobalFlags *flag.FlagSet
+
+	// IsolateCommandLine, when true, uses a new flag.FlagSet with the
+	// flag.ContinueOnError handling for the top-level group, instead of the
+	// flag.CommandLine, so that the flags defined on the flag.CommandLine by
+	// the host program or the imported libraries are not accepted and are not
+	// documented as the inherited flags.
+	IsolateCommandLine bool
+
+	// InterspersedFlags, when true, allows flags and positional arguments to
+	// appear in any order after the command is resolved, similar to the GNU
+	// style. Positional arguments are passed to the command in the same order
+	// and a "--" argument still stops the flag parsing. Commands implementing
+	// PassThrough() bool can opt out of this behavior.
+	InterspersedFlags bool
+
+	// DefaultCommand, when non-empty, names the top-level command that runs
+	// when the command-line does not select any command, instead of printing
+	// the help. Flags for the default command are not accepted in that case,
+	// because they are only known after the command is selected.
+	DefaultCommand string
+
+	// CommandFromEnv, when non-empty, names an environment variable that holds
+	// the space separated command path, as in "server start", which is used
+	// when the command-line doesn't select a command. It takes precedence over
+	// the DefaultCommand when the variable is set. As with the DefaultCommand,
+	// flags of the selected commands are not accepted in the command-line.
+	CommandFromEnv string
+
+	// CombinedShortFlags, when true, accepts multiple single-character boolean
+	// flags combined after a single dash, as in "-abc" for "-a -b -c", when the
+	// argument is not a defined flag by itself. Combined flags cannot take
+	// values.
+	CombinedShortFlags bool
+
+	// GNUFlags, when true, enforces the GNU conventions for the flag names:
+	// multi-character flag names require the "--" prefix and single-character
+	// flag names require the "-" prefix. Other forms fail with an
+	// [ErrBadFlagSyntax] error. With the CombinedShortFlags, a single-dash
+	// argument like "-abc" is always treated as combined short flags.
+	GNUFlags bool
+
+	// GetoptLong, when true, parses the flags following the GNU getopt_long
+	// conventions. It implies the GNUFlags, CombinedShortFlags,
+	// InterspersedFlags and AbbreviatedFlags, and also accepts the values
+	// attached to the short flags, as in "-fvalue" or "-abfvalue" for "-a -b
+	// -f value". Values attached to the short boolean flags, as in "-v=true",
+	// are rejected. Arguments starting with a '+' are never flags.
+	GetoptLong bool
+
+	// FlagParser, when non-nil, decodes the flag arguments instead of the
+	// built-in parser, which implements the GNUFlags, CombinedShortFlags and
+	// GetoptLong options. Other options, like the AbbreviatedFlags and the
+	// StrictFlagValues, still apply to the flags it returns.
+	FlagParser FlagParser
+
+	// PreserveTerminator, when true, keeps the "--" argument that stops the
+	// flag parsing in the arguments passed to the command, so that commands
+	// running other programs can forward it. With the InterspersedFlags, the
+	// positional arguments collected before the "--" argument still come
+	// first.
+	PreserveTerminator bool
+
+	// StrictTerminatedArgs, when true, fails with an [ErrInvalidArgs] error
+	// when an argument after the "--" looks like a flag, as in "-x", which is
+	// usually a misplaced flag. It only applies to the commands that validate
+	// their arguments; see the ValidateArgs method in [Command].
+	StrictTerminatedArgs bool
+
+	// Stdout and Stderr, when non-nil, replace os.Stdout and os.Stderr as the
+	// destinations for the documentation and the diagnostic messages printed by
+	// the package.
+	Stdout, Stderr io.Writer
+
+	// Stdin, when non-nil, replaces os.Stdin as the standard input for the
+	// commands; see [Stdin].
+	Stdin io.Reader
+
+	// HelpOnErrorToStderr, when true, writes the help that is printed for an
+	// incomplete command-line, as when a group is invoked without a
+	// subcommand, to Stderr instead of Stdout, so that Stdout stays clean for
+	// the pipes. Output of the help command and the -h flag is always written
+	// to Stdout.
+	HelpOnErrorToStderr bool
+
+	// ShowUsageOnError, when set, is called with the error from a Run, and if
+	// it returns true, the help for the command selected by the command-line
+	// is printed before the error is returned. Help is written to Stdout, or to
+	// Stderr with the HelpOnErrorToStderr. Use [IsUsageError] to show the help
+	// only for the command-line parsing errors, but not for the errors returned
+	// by the commands.
+	ShowUsageOnError func(err error) bool
+
+	// InteractivePrompts, when true, prompts for the values of the required
+	// flags that are not set in the command-line, when the standard input is
+	// a terminal; see [MarkFlagRequired]. Otherwise, missing required flags
+	// fail with an [ErrFlagConstraint] error, as they do for the secret flags
+	// on the platforms where the input echo cannot be disabled; see
+	// [SecretVar].
+	InteractivePrompts bool
+
+	// EchoFlag, when true, adds a global "-echo" flag, which prints the
+	// resolved command path, flags and arguments to Stderr before running the
+	// command.
+	EchoFlag bool
+
+	// QuietFlag, when true, adds a global "-quiet" flag, with the "-q" alias,
+	// which suppresses the informational output of the package, like the
+	// deprecation warnings. Errors are still reported. Commands can check the
+	// flag with [Quiet] to adjust their own verbosity. Run fails if the
+	// GlobalFlags define a "quiet" or "q" flag.
+	QuietFlag bool
+
+	// FlagErrorHandling, when true, handles the flag parsing errors as per the
+	// error handling of the flag.FlagSet that defines the flag, same as
+	// flag.FlagSet.Parse: the process exits with status 2 for the
+	// flag.ExitOnError and panics for the flag.PanicOnError. By default, flag
+	// parsing errors are always returned.
+	FlagErrorHandling bool
+
+	// HandleSignals, when true, cancels the context passed to the command on
+	// the first SIGINT or SIGTERM signal and terminates the process on the
+	// second, with exit status 130 for SIGINT and 143 for SIGTERM as the
+	// shells report. Signal handlers are removed when Run returns.
+	HandleSignals bool
+
+	// CaseInsensitive, when true, matches the command names from the
+	// command-line ignoring the case. Command names are still displayed in the
+	// documentation as they are defined. Flag names are always case-sensitive.
+	// Run fails if the command names in a group differ only in case.
+	CaseInsensitive bool
+
+	// AbbreviatedCommands, when true, allows commands to be selected by an
+	// unambiguous prefix of their names. Prefixes matching more than one
+	// command fail with an "ambiguous command" error.
+	AbbreviatedCommands bool
+
+	// AbbreviatedFlags, when true, allows flags to be given by an unambiguous
+	// prefix of their names, as in "--con" for "--connect-port", when no flag
+	// has the exact name. Prefixes matching more than one flag fail with an
+	// [ErrAmbiguousFlag] error. The help flags, and with the GNUFlags the
+	// single-dash flags, are never abbreviated.
+	AbbreviatedFlags bool
+
+	// NoDeprecationWarningsEnv, when non-empty, names an environment variable,
+	// like "MYAPP_NO_DEPRECATION_WARNINGS", which turns off the warnings for
+	// the deprecated commands and flags when it is set to a true value, as
+	// accepted by strconv.ParseBool.
+	NoDeprecationWarningsEnv string
+
+	// HelpCommand, FlagsCommand and CommandsCommand, when non-empty, replace
+	// the names for the built-in "help", "flags" and "commands" commands
+	// respectively.
+	HelpCommand, FlagsCommand, CommandsCommand string
+
+	// DisabledBuiltins lists the built-in commands, by their default names
+	// "help", "flags" or "commands", that are disabled. Disabled built-in
+	// commands are not documented and their names are treated as ordinary
+	// command names.
+	DisabledBuiltins []string
+
+	// Middleware holds the functions that wrap every command execution. They
+	// are applied in the order such that the first middleware is the
+	// outermost. Also see [Options.Use].
+	Middleware []Middleware
+
+	// ContextDecorator, when set, returns the context that is passed to the
+	// commands, derived from the input context. It is called after the command
+	// is resolved, so it can inject the shared dependencies, like loggers or
+	// database handles, and can use [CommandPath] to inspect the command.
+	ContextDecorator func(ctx context.Context) context.Context
+
+	// OnComplete, when set, is called after the selected command returns, with
+	// the command path, the run time, including the middleware, and the error
+	// returned, which may be nil. It is not called for the built-in commands
+	// or when the command-line is invalid. It is a lighter alternative to the
+	// Middleware for reporting metrics.
+	OnComplete func(path []string, dur time.Duration, err error)
+
+	// RecoverPanics, when true, recovers from panics in the commands and
+	// returns them as errors that include the stack trace.
+	RecoverPanics bool
+
+	// PreserveOrder, when true, lists the subcommands in the documentation in
+	// the same order as they are defined, instead of sorting them by the names.
+	PreserveOrder bool
+
+	// MergeGroups, when true, lists the subcommand groups together with the
+	// other subcommands in the documentation, instead of in a separate
+	// section.
+	MergeGroups bool
+
+	// ConfigFlag, when non-empty, names a top-level flag that holds the path
+	// to a config file with the default flag values; see [LoadDefaults]. When
+	// the flag value is non-empty, the config file is applied to all flags of
+	// the selected command, except for the flags set in the command-line.
+	ConfigFlag string
+
+	// RejectShadowedFlags, when true, fails the command-line parsing with an
+	// [ErrShadowedFlag] error when a flag from the command-line is defined by
+	// more than one command in the command path. By default, the flag from the
+	// deepest command silently takes precedence over the parent flags.
+	RejectShadowedFlags bool
+
+	// ResetFlagValues, when true, restores the flags of the top-level and the
+	// selected commands to their default values before parsing the
+	// command-line, so that the values from a previous Run with the same
+	// commands do not leak into the next one; see [ResetFlags]. Flags from the
+	// flag.CommandLine are not reset.
+	ResetFlagValues bool
+
+	// StrictFlagValues, when true, fails the command-line parsing with an
+	// [ErrFlagNeedsArg] error when a non-boolean flag without the "=value"
+	// form is followed by an argument that looks like a defined flag, as in
+	// "-name -v", instead of using that argument as the flag value. Values
+	// that start with a dash can still be given as "-name=-v".
+	StrictFlagValues bool
+
+	// UsageTemplate, when non-empty, is a [text/template] for the usage line
+	// in the help output. The template data has the fields Command, which
+	// holds the command path, HasFlags and IsGroup, which report if the
+	// command accepts flags and subcommands, and Args, which holds the
+	// placeholder for the positional arguments, which may be empty; see the
+	// ArgNames() []string method in [Command]. Commands implementing the
+	// Usage() string method override the usage line after the command path.
+	//
+	// Example:
+	//
+	//	"{{.Command}}{{if .HasFlags}} [options]{{end}} {{.Args}}"
+	UsageTemplate string
+
+	// ErrorFormat selects how [Options.Main] prints the errors to Stderr.
+	// Default is ErrorFormatText.
+	ErrorFormat ErrorFormat
+
+	// Purpose and Description, when non-empty, hold the short and long
+	// documentation for the program itself, which are printed by the help at
+	// the top-level. Description takes precedence over the Purpose in the help
+	// output, same as for the commands.
+	Purpose, Description string
+
+	// Color selects when the documentation output is colorized. Default is
+	// ColorAuto.
+	Color ColorMode
+
+	// FlagsTitle and InheritedFlagsTitle, when non-empty, replace the "Flags"
+	// and the "Inherited Flags" section titles in the help output, as in
+	// "Command Options" and "Global Options".
+	FlagsTitle, InheritedFlagsTitle string
+
+	// InheritedFlagsFirst, when true, prints the inherited flags of the parent
+	// commands before the flags of the command in the help output.
+	InheritedFlagsFirst bool
+}
+
+// Middleware wraps a command execution to add cross-cutting behavior, like
+// logging, timing or authorization checks.
+//
+// Example:
+//
+//	timing := func(next cli.CmdFunc) cli.CmdFunc {
+//	    return func(ctx context.Context, args []string) error {
+//	        defer func(start time.Time) {
+//	            log.Printf("took %v", time.Since(start))
+//	        }(time.Now())
+//	        return next(ctx, args)
+//	    }
+//	}
+type Middleware func(next CmdFunc) CmdFunc
+
+// Use appends the middleware to the options.
+func (opts *Options) Use(mw ...Middleware) {
+	opts.Middleware = append(opts.Middleware, mw...)
+}
+
+// wrap returns the command function wrapped by all middleware.
+func (opts *Options) wrap(fun CmdFunc) CmdFunc {
+	for i := len(opts.Middleware) - 1; i >= 0; i-- {
+		fun = opts.Middleware[i](fun)
+	}
+	if opts.RecoverPanics {
+		fun = recoverPanics(fun)
+	}
+	return fun
+}
+
+// recoverPanics returns a command function that converts panics into errors.
+func recoverPanics(fun CmdFunc) CmdFunc {
+	return func(ctx context.Context, args []string) (status error) {
+		defer func() {
+			if r := recover(); r != nil {
+				if err, ok := r.(error); ok {
+					status = fmt.Errorf("command panicked: %w\n%s", err, debug.Stack())
+				} else {
+					status = fmt.Errorf("command panicked: %v\n%s", r, debug.Stack())
+				}
+			}
+		}()
+		return fun(ctx, args)
+	}
+}
+
+// isEnabled reports true if the built-in command is not disabled.
+func (opts *Options) isEnabled(special string) bool {
+	return !slices.Contains(opts.DisabledBuiltins, special)
+}
+
+// specialName returns the configured name for a built-in command.
+func (opts *Options) specialName(special string) string {
+	var name string
+	switch special {
+	case "help":
+		name = opts.HelpCommand
+	case "flags":
+		name = opts.FlagsCommand
+	case "commands":
+		name = opts.CommandsCommand
+	}
+	if len(name) == 0 {
+		return special
+	}
+	return name
+}
+
+// isSpecial reports true if the command name is used by a built-in command.
+func (opts *Options) isSpecial(name string) bool {
+	_, ok := opts.lookupSpecial(name)
+	return ok
+}
+
+// lookupSpecial returns the built-in command for the given command name, if
+// any.
+func (opts *Options) lookupSpecial(name string) (string, bool) {
+	for _, special := range specialCmds {
+		if !opts.isEnabled(special) {
+			continue
+		}
+		if opts.cmdKey(name) == opts.cmdKey(opts.specialName(special)) {
+			return special, true
+		}
+	}
+	return "", false
+}
+
+// deprecationsSilenced reports true if the deprecation warnings are turned
+// off by the environment.
+func (opts *Options) deprecationsSilenced() bool {
+	if len(opts.NoDeprecationWarningsEnv) == 0 {
+		return false
+	}
+	v, err := strconv.ParseBool(os.Getenv(opts.NoDeprecationWarningsEnv))
+	return err == nil && v
+}
+
+// cmdKey returns the lookup key for a command name.
+func (opts *Options) cmdKey(name string) string {
+	if opts.CaseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+func (opts *Options) stdin() io.Reader {
+	if opts.Stdin == nil {
+		return os.Stdin
+	}
+	return opts.Stdin
+}
+
+func (opts *Options) stdout() io.Writer {
+	if opts.Stdout == nil {
+		return os.Stdout
+	}
+	return opts.Stdout
+}
+
+func (opts *Options) stderr() io.Writer {
+	if opts.Stderr == nil {
+		return os.Stderr
+	}
+	return opts.Stderr
+}
+
+// flagsTitle returns the section title for the command flags.
+func (opts *Options) flagsTitle() string {
+	if len(opts.FlagsTitle) > 0 {
+		return opts.FlagsTitle
+	}
+	return "Flags"
+}
+
+// inheritedFlagsTitle returns the section title for the inherited flags.
+func (opts *Options) inheritedFlagsTitle() string {
+	if len(opts.InheritedFlagsTitle) > 0 {
+		return opts.InheritedFlagsTitle
+	}
+	return "Inherited Flags"
+}
+
+// helpOnError returns the destination for the help output that is printed for
+// an incomplete command-line.
+func (opts *Options) helpOnError() io.Writer {
+	if opts.HelpOnErrorToStderr {
+		return opts.stderr()
+	}
+	return opts.stdout()
+}
+
+// isAllowed reports true if the command at the given path is not rejected by
+// the command filter.
+func (opts *Options) isAllowed(path []string) bool {
+	if opts.CommandFilter == nil {
+		return true
+	}
+	return opts.CommandFilter(path)
+}
+
+// rootFlags returns the flag.FlagSet for the top-level group, which includes
+// the global flags, if any. Flags that are added by the package itself are
+// bound to the root group.
+func (opts *Options) rootFlags(root *groupCmd) (*flag.FlagSet, error) {
+	if opts.GlobalFlags == nil && !opts.EchoFlag && !opts.QuietFlag && !opts.IsolateCommandLine {
+		return flag.CommandLine, nil
+	}
+	fset := flag.NewFlagSet(flag.CommandLine.Name(), flag.ContinueOnError)
+	if opts.EchoFlag {
+		fset.BoolVar(&root.echo, "echo", false, "print the resolved command-line before running the command")
+	}
+	if opts.QuietFlag {
+		for _, name := range []string{"quiet", "q"} {
+			if opts.GlobalFlags != nil && opts.GlobalFlags.Lookup(name) != nil {
+				return nil, fmt.Errorf("global flag -%s conflicts with the quiet flag: %w", name, os.ErrExist)
+			}
+		}
+		fset.BoolVar(&root.quiet, "quiet", false, "suppress the informational output")
+		if err := AliasFlag(fset, "quiet", "q"); err != nil {
+			return nil, err
+		}
+	}
+	inherit := func(from *flag.FlagSet) {
+		from.VisitAll(func(f *flag.Flag) {
+			if fset.Lookup(f.Name) == nil {
+				copyFlag(fset, f)
+			}
+		})
+	}
+	if opts.GlobalFlags != nil {
+		inherit(opts.GlobalFlags)
+	}
+	if !opts.IsolateCommandLine {
+		inherit(flag.CommandLine)
+	}
+	return fset, nil
+}
+
+// newRoot validates the top-level commands and returns the root group for
+// them.
+func (opts *Options) newRoot(cmds []Command) (*groupCmd, error) {
+	if cmds == nil {
+		return nil, os.ErrInvalid
+	}
+	for _, c := range cmds {
+		if c == nil {
+			return nil, fmt.Errorf("command cannot be nil: %w", os.ErrInvalid)
+		}
+		name := cmdName(c)
+		if err := CheckName(name); err != nil {
+			return nil, err
+		}
+		if opts.isSpecial(name) {
+			return nil, fmt.Errorf("command name %q is reserved for the built-in command", name)
+		}
+	}
+	if err := opts.checkUnique(nil, cmds); err != nil {
+		return nil, err
+	}
+	if len(opts.UsageTemplate) > 0 {
+		if _, err := template.New("usage").Parse(opts.UsageTemplate); err != nil {
+			return nil, fmt.Errorf("invalid usage template: %w", err)
+		}
+	}
+	if len(opts.DefaultCommand) > 0 && !slices.ContainsFunc(cmds, func(c Command) bool {
+		return opts.cmdKey(cmdName(c)) == opts.cmdKey(opts.DefaultCommand)
+	}) {
+		return nil, fmt.Errorf("default command %q is not defined: %w", opts.DefaultCommand, os.ErrInvalid)
+	}
+	if opts.GetoptLong {
+		gnu := *opts
+		gnu.GNUFlags, gnu.CombinedShortFlags, gnu.InterspersedFlags, gnu.AbbreviatedFlags = true, true, true, true
+		opts = &gnu
+	}
+	root := &groupCmd{
+		subcmds:     cmds,
+		opts:        opts,
+		purpose:     opts.Purpose,
+		description: opts.Description,
+	}
+	fset, err := opts.rootFlags(root)
+	if err != nil {
+		return nil, err
+	}
+	root.flags = fset
+	return root, nil
+}
+
+// trimArgs returns os.Args[1:] if the input is os.Args itself.
+func trimArgs(args []string) []string {
+	if len(args) != 0 {
+		if &args[0] == &os.Args[0] {
+			return os.Args[1:]
+		}
+	}
+	return args
+}
+
+// Run is similar to the package level [Run] function, but customizes the CLI
+// behavior as per the options.
+func (opts *Options) Run(ctx context.Context, cmds []Command, args []string) error {
+	_, err := opts.runRoot(ctx, cmds, args)
+	return err
+}
+
+// runRoot runs the command selected by the arguments and returns the root
+// group, if the commands are valid, along with the error.
+func (opts *Options) runRoot(ctx context.Context, cmds []Command, args []string) (*groupCmd, error) {
+	root, err := opts.newRoot(cmds)
+	if err != nil {
+		return nil, err
+	}
+	if opts.HandleSignals {
+		sctx, stop := withSignals(ctx)
+		defer stop()
+		ctx = sctx
+	}
+	return root, root.run(ctx, trimArgs(args))
+}
+
+// Resolve is similar to the package level [Resolve] function, but customizes
+// the parsing behavior as per the options.
+func (opts *Options) Resolve(cmds []Command, args []string) (path []string, remaining []string, special string, err error) {
+	root, err := opts.newRoot(cmds)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	cmdpath, remaining, err := root.resolve(context.Background(), trimArgs(args))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return cmdNames(cmdpath), remaining, root.specialCmd, nil
+}
+
+// withSignals returns a context that is canceled on the first SIGINT or
+// SIGTERM signal. Process exits on the second signal with status 130 for
+// SIGINT and 143 for SIGTERM. Returned stop function must be called to remove
+// the signal handlers.
+func withSignals(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	sigch := make(chan os.Signal, 2)
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sigch:
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case sig := <-sigch:
+			// exit status is 128 plus the signal number, as the shells report
+			if sig == syscall.SIGTERM {
+				os.Exit(143)
+			}
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(sigch)
+		close(done)
+		cancel()
+	}
+	return ctx, stop
+}
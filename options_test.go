@@ -0,0 +1,965 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCommandFilter(t *testing.T) {
+	ctx := context.Background()
+
+	get := newTestCmd("get")
+	drop := newTestCmd("drop")
+	db := NewGroup("db", "manage database", get, drop)
+
+	opts := &Options{
+		CommandFilter: func(path []string) bool {
+			return !slices.Equal(path, []string{"db", "drop"})
+		},
+	}
+
+	if err := opts.Run(ctx, []Command{db}, []string{"db", "get", "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(get.args) != 1 || get.args[0] != "key" {
+		t.Fatalf("want `key`, got %v", get.args)
+	}
+
+	err := opts.Run(ctx, []Command{db}, []string{"db", "drop"})
+	if err == nil || !strings.Contains(err.Error(), "command not available: drop") {
+		t.Fatalf("want command not available error, got %v", err)
+	}
+
+	gc := &groupCmd{subcmds: []Command{db}, opts: opts}
+	cmdpath, _, err := gc.resolve(ctx, []string{"db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range getSubcommands(opts, cmdpath) {
+		if sub[0] == "drop" {
+			t.Fatalf("filtered command drop must not be listed")
+		}
+	}
+}
+
+func TestGlobalFlags(t *testing.T) {
+	ctx := context.Background()
+
+	gflags := flag.NewFlagSet("global", flag.ContinueOnError)
+	verbose := gflags.Bool("verbose", false, "enable verbose output")
+	level := gflags.String("log-level", "info", "logging level")
+
+	start := newTestCmd("start")
+	server := NewGroup("server", "manage server", start)
+
+	opts := &Options{GlobalFlags: gflags}
+	args := []string{"server", "-verbose", "start", "--log-level=debug", "arg"}
+	if err := opts.Run(ctx, []Command{server}, args); err != nil {
+		t.Fatal(err)
+	}
+	if !*verbose {
+		t.Fatalf("want verbose to be true")
+	}
+	if *level != "debug" {
+		t.Fatalf("want log-level debug, got %q", *level)
+	}
+	if len(start.args) != 1 || start.args[0] != "arg" {
+		t.Fatalf("want `arg`, got %v", start.args)
+	}
+
+	root, err := opts.newRoot([]Command{server})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdpath, _, err := root.resolve(ctx, []string{"server", "start"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	iflags, _ := getInheritedFlags(cmdpath)
+	if iflags.Lookup("verbose") == nil || iflags.Lookup("log-level") == nil {
+		t.Fatalf("want global flags to be inherited")
+	}
+}
+
+func TestGlobalFlagMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	gflags := flag.NewFlagSet("global", flag.ContinueOnError)
+	verbose := gflags.Bool("verbose", false, "enable verbose output")
+	project := gflags.String("project", "", "project name")
+	gflags.String("region", "", "deployment region")
+	gflags.String("zone", "", "deployment zone")
+	gflags.String("addr", "", "server address")
+	for _, err := range []error{
+		AliasFlag(gflags, "verbose", "v"),
+		MarkFlagRequired(gflags, "project"),
+		SetFlagValidator(gflags, "region", func(v string) error {
+			if !strings.HasPrefix(v, "us-") {
+				return errors.New("unknown region")
+			}
+			return nil
+		}),
+		MarkFlagDeprecated(gflags, "addr", "use -region"),
+		SetFlagCategory(gflags, "region", "Deployment"),
+		MarkFlagsMutuallyExclusive(gflags, "region", "zone"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run := newTestCmd("run")
+	var stdout, stderr strings.Builder
+	opts := &Options{GlobalFlags: gflags, IsolateCommandLine: true, Stdout: &stdout, Stderr: &stderr}
+	if err := opts.Run(ctx, []Command{run}, []string{"-v", "-project=p", "-addr=x", "run"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*verbose || *project != "p" {
+		t.Fatalf("want verbose and project from the command-line, got %v and %q", *verbose, *project)
+	}
+	if !strings.Contains(stderr.String(), "use -region") {
+		t.Fatalf("want deprecation warning for the global flag, got %q", stderr.String())
+	}
+
+	for _, test := range []struct {
+		args []string
+		want error
+	}{
+		{[]string{"run"}, ErrFlagConstraint},
+		{[]string{"-project=p", "-region=eu-west", "run"}, ErrInvalidFlagValue},
+		{[]string{"-project=p", "-region=us-east", "-zone=a", "run"}, ErrFlagConstraint},
+	} {
+		*project = ""
+		if err := opts.Run(ctx, []Command{run}, test.args); !errors.Is(err, test.want) {
+			t.Errorf("Run(%q): want %v, got %v", test.args, test.want, err)
+		}
+	}
+
+	stdout.Reset()
+	if err := opts.Run(ctx, []Command{run}, []string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := stdout.String(); !strings.Contains(out, "-v, --verbose") || !strings.Contains(out, "Deployment:") {
+		t.Fatalf("want alias and category for the global flags, got %q", out)
+	}
+}
+
+func TestGlobalFlagRelease(t *testing.T) {
+	ctx := context.Background()
+
+	var released atomic.Bool
+	run := func() error {
+		gflags := flag.NewFlagSet("global", flag.ContinueOnError)
+		gflags.Bool("verbose", false, "enable verbose output")
+		if err := AliasFlag(gflags, "verbose", "v"); err != nil {
+			t.Fatal(err)
+		}
+		runtime.SetFinalizer(getFlagMeta(gflags, "verbose", false), func(*flagMeta) { released.Store(true) })
+		opts := &Options{GlobalFlags: gflags, IsolateCommandLine: true}
+		return opts.Run(ctx, []Command{newTestCmd("run")}, []string{"run", "-x"})
+	}
+
+	// Usage from the error still finds the metadata of the global flags.
+	err := run()
+	runtime.GC()
+	if usage := ErrorUsage(err); !strings.Contains(usage, "-v, --verbose\n") {
+		t.Fatalf("want the global flag aliases in the usage, got %q", usage)
+	}
+	// Metadata is released with the error, without any explicit release.
+	err = nil
+	for i := 0; i < 10 && !released.Load(); i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !released.Load() {
+		t.Fatalf("want the global flag metadata to be released")
+	}
+}
+
+func TestInterspersedFlags(t *testing.T) {
+	ctx := context.Background()
+
+	run := newTestCmd("run")
+	background := run.flags.Bool("background", false, "set to run in background")
+	format := run.flags.String("format", "text", "output format")
+
+	opts := &Options{InterspersedFlags: true}
+	args := []string{"run", "a.txt", "-background", "b.txt", "-format", "json", "--", "-c.txt"}
+	if err := opts.Run(ctx, []Command{run}, args); err != nil {
+		t.Fatal(err)
+	}
+	if !*background || *format != "json" {
+		t.Fatalf("want background and json format, got %v and %q", *background, *format)
+	}
+	if want := []string{"a.txt", "b.txt", "-c.txt"}; !slices.Equal(run.args, want) {
+		t.Fatalf("want %v, got %v", want, run.args)
+	}
+
+	*background = false
+	if err := Run(ctx, []Command{run}, []string{"run", "a.txt", "-background"}); err != nil {
+		t.Fatal(err)
+	}
+	if *background {
+		t.Fatalf("want flags after arguments to be ignored by default")
+	}
+	if want := []string{"a.txt", "-background"}; !slices.Equal(run.args, want) {
+		t.Fatalf("want %v, got %v", want, run.args)
+	}
+}
+
+func TestEchoFlag(t *testing.T) {
+	ctx := context.Background()
+
+	scan := newTestCmd("scan")
+	scan.flags.String("format", "text", "output format")
+	db := NewGroup("db", "manage database", scan)
+
+	var stderr strings.Builder
+	opts := &Options{EchoFlag: true, Stderr: &stderr}
+	args := []string{"-echo", "db", "scan", "-format", "json", "prefix", "a b"}
+	if err := opts.Run(ctx, []Command{db}, args); err != nil {
+		t.Fatal(err)
+	}
+	if want := "> db scan --format=json prefix \"a b\"\n"; stderr.String() != want {
+		t.Fatalf("want %q, got %q", want, stderr.String())
+	}
+}
+
+type passThroughCmd struct {
+	*TestCmd
+}
+
+func (p *passThroughCmd) PassThrough() bool {
+	return true
+}
+
+func TestPassThrough(t *testing.T) {
+	ctx := context.Background()
+
+	exec := &passThroughCmd{newTestCmd("exec")}
+	verbose := exec.flags.Bool("v", false, "verbose output")
+
+	opts := &Options{InterspersedFlags: true}
+	args := []string{"exec", "-v", "ls", "-l", "-v"}
+	if err := opts.Run(ctx, []Command{exec}, args); err != nil {
+		t.Fatal(err)
+	}
+	if !*verbose {
+		t.Fatalf("want flags before the first argument to be parsed")
+	}
+	if want := []string{"ls", "-l", "-v"}; !slices.Equal(exec.args, want) {
+		t.Fatalf("want %v, got %v", want, exec.args)
+	}
+}
+
+func TestHandleSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending interrupt signal is not supported on windows")
+	}
+	ctx := context.Background()
+
+	wait := NewCommand("wait", func(ctx context.Context, args []string) error {
+		p, err := os.FindProcess(os.Getpid())
+		if err != nil {
+			return err
+		}
+		if err := p.Signal(os.Interrupt); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}, nil, "wait for a signal")
+
+	opts := &Options{HandleSignals: true}
+	if err := opts.Run(ctx, []Command{wait}, []string{"wait"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want context.Canceled, got %v", err)
+	}
+}
+
+func TestHandleSignalsExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sending interrupt signal is not supported on windows")
+	}
+	if os.Getenv("CLI_TEST_SIGNAL_EXIT") == "1" {
+		ctx := context.Background()
+
+		// The command ignores the cancellation, so the second signal exits.
+		wait := NewCommand("wait", func(ctx context.Context, args []string) error {
+			p, err := os.FindProcess(os.Getpid())
+			if err != nil {
+				return err
+			}
+			p.Signal(os.Interrupt)
+			<-ctx.Done()
+			p.Signal(os.Interrupt)
+			time.Sleep(10 * time.Second)
+			return nil
+		}, nil, "wait for the signals")
+		opts := &Options{HandleSignals: true}
+		opts.Run(ctx, []Command{wait}, []string{"wait"})
+		os.Exit(0)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHandleSignalsExit$")
+	cmd.Env = append(os.Environ(), "CLI_TEST_SIGNAL_EXIT=1")
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 130 {
+		t.Fatalf("want exit status 130, got %v", err)
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	ctx := context.Background()
+
+	start := newTestCmd("start")
+	server := NewGroup("server", "manage server", start)
+
+	if err := Run(ctx, []Command{server}, []string{"Server", "Start"}); err == nil {
+		t.Fatalf("want error for case mismatch by default")
+	}
+
+	opts := &Options{CaseInsensitive: true}
+	if err := opts.Run(ctx, []Command{server}, []string{"Server", "START", "arg"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(start.args) != 1 || start.args[0] != "arg" {
+		t.Fatalf("want `arg`, got %v", start.args)
+	}
+
+	// Names that differ only in case collide, same as with the Dispatcher.
+	cmds := []Command{newTestCmd("List"), NewGroup("server", "manage server", newTestCmd("list"), newTestCmd("LIST"))}
+	if err := opts.Run(ctx, cmds, []string{"list"}); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("want os.ErrExist for case-folded duplicates, got %v", err)
+	}
+	if _, err := NewDispatcher(opts, cmds); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("want os.ErrExist from the dispatcher, got %v", err)
+	}
+	if err := Run(ctx, cmds, []string{"List"}); err != nil {
+		t.Fatalf("want case-sensitive names to be distinct, got %v", err)
+	}
+}
+
+func TestAbbreviatedCommands(t *testing.T) {
+	ctx := context.Background()
+
+	commit := newTestCmd("commit")
+	config := newTestCmd("config")
+	status := newTestCmd("status")
+	cmds := []Command{commit, config, status}
+
+	if err := Run(ctx, cmds, []string{"st"}); err == nil {
+		t.Fatalf("want error for abbreviated command by default")
+	}
+
+	opts := &Options{AbbreviatedCommands: true}
+	if err := opts.Run(ctx, cmds, []string{"st", "arg"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.args) != 1 || status.args[0] != "arg" {
+		t.Fatalf("want `arg`, got %v", status.args)
+	}
+	if err := opts.Run(ctx, cmds, []string{"commit"}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := opts.Run(ctx, cmds, []string{"co"})
+	if err == nil || err.Error() != "ambiguous command: co (candidates: commit, config)" {
+		t.Fatalf("want ambiguous command error, got %v", err)
+	}
+
+	// Built-in names win over the prefix matches.
+	helper := newTestCmd("helper")
+	var stdout strings.Builder
+	opts = &Options{AbbreviatedCommands: true, Stdout: &stdout}
+	if err := opts.Run(ctx, []Command{helper, status}, []string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	if helper.args != nil || !strings.Contains(stdout.String(), "helper") {
+		t.Fatalf("want the help output, got args %v and output %q", helper.args, stdout.String())
+	}
+
+	// Candidates are listed with their defined names.
+	opts = &Options{AbbreviatedCommands: true, CaseInsensitive: true}
+	err = opts.Run(ctx, []Command{newTestCmd("Commit"), newTestCmd("Config")}, []string{"co"})
+	if err == nil || err.Error() != "ambiguous command: co (candidates: Commit, Config)" {
+		t.Fatalf("want ambiguous command error with defined names, got %v", err)
+	}
+}
+
+func TestAbbreviatedFlags(t *testing.T) {
+	ctx := context.Background()
+
+	var port, timeout int
+	var connect string
+	fset := flag.NewFlagSet("dial", flag.ContinueOnError)
+	fset.StringVar(&connect, "connect", "", "address to connect")
+	fset.IntVar(&port, "connect-port", 0, "port to connect")
+	fset.IntVar(&timeout, "timeout", 0, "timeout in seconds")
+	cmd := NewCommand("dial", func(context.Context, []string) error { return nil }, fset, "Dial a server")
+
+	if err := Run(ctx, []Command{cmd}, []string{"dial", "--time=5"}); !errors.Is(err, ErrUnknownFlag) {
+		t.Fatalf("want ErrUnknownFlag for abbreviated flag by default, got %v", err)
+	}
+
+	opts := &Options{AbbreviatedFlags: true}
+	if err := opts.Run(ctx, []Command{cmd}, []string{"dial", "--time=5", "--connect-p", "80", "--connect", "host"}); err != nil {
+		t.Fatal(err)
+	}
+	if timeout != 5 || port != 80 || connect != "host" {
+		t.Fatalf("want 5, 80 and `host`, got %d, %d and %q", timeout, port, connect)
+	}
+
+	err := opts.Run(ctx, []Command{cmd}, []string{"dial", "--con=x"})
+	if !errors.Is(err, ErrAmbiguousFlag) || !strings.Contains(err.Error(), "ambiguous flag: -con (candidates: -connect, -connect-port)") {
+		t.Fatalf("want ambiguous flag error, got %v", err)
+	}
+}
+
+func TestReservedCommandNames(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range []string{"help", "flags", "commands"} {
+		cmds := []Command{newTestCmd("run"), newTestCmd(name)}
+		err := Run(ctx, cmds, []string{"run"})
+		if want := fmt.Sprintf("command name %q is reserved", name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("want reserved name error for %q, got %v", name, err)
+		}
+	}
+
+	// Special command names are allowed inside the groups.
+	help := newTestCmd("help")
+	if err := Run(ctx, []Command{NewGroup("db", "manage database", help)}, []string{"db", "help", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(help.args) != 1 || help.args[0] != "x" {
+		t.Fatalf("want `x`, got %v", help.args)
+	}
+}
+
+func TestDisabledBuiltins(t *testing.T) {
+	ctx := context.Background()
+
+	run := newTestCmd("run")
+	cmds := []Command{run}
+
+	var stdout strings.Builder
+	opts := &Options{DisabledBuiltins: []string{"flags", "commands"}, Stdout: &stdout}
+	if err := opts.Run(ctx, cmds, []string{"flags"}); err == nil || !strings.Contains(err.Error(), "command not defined: flags") {
+		t.Fatalf("want command not defined error, got %v", err)
+	}
+	if err := opts.Run(ctx, cmds, []string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := stdout.String(); strings.Contains(out, "\tflags") || strings.Contains(out, "\tcommands") {
+		t.Fatalf("want disabled built-in commands to be omitted, got %q", out)
+	}
+
+	// Names of the disabled built-in commands are not reserved.
+	commands := newTestCmd("commands")
+	if err := opts.Run(ctx, []Command{commands}, []string{"commands", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(commands.args) != 1 || commands.args[0] != "x" {
+		t.Fatalf("want `x`, got %v", commands.args)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	ctx := context.Background()
+
+	var trace []string
+	tracer := func(name string) Middleware {
+		return func(next CmdFunc) CmdFunc {
+			return func(ctx context.Context, args []string) error {
+				trace = append(trace, name+":"+strings.Join(args, ","))
+				return next(ctx, args)
+			}
+		}
+	}
+	run := NewCommand("run", func(ctx context.Context, args []string) error {
+		trace = append(trace, "run")
+		return nil
+	}, nil, "run things")
+
+	opts := new(Options)
+	opts.Use(tracer("outer"), tracer("inner"))
+	if err := opts.Run(ctx, []Command{run}, []string{"run", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"outer:a,b", "inner:a,b", "run"}; !slices.Equal(trace, want) {
+		t.Fatalf("want %v, got %v", want, trace)
+	}
+}
+
+func TestOnComplete(t *testing.T) {
+	ctx := context.Background()
+
+	errFailed := errors.New("failed")
+	fail := NewCommand("fail", func(ctx context.Context, args []string) error {
+		time.Sleep(time.Millisecond)
+		return errFailed
+	}, nil, "always fails")
+	jobs := NewGroup("jobs", "manage jobs", fail)
+
+	var calls int
+	var gotPath []string
+	var gotDur time.Duration
+	var gotErr error
+	opts := &Options{
+		Stdout: io.Discard,
+		OnComplete: func(path []string, dur time.Duration, err error) {
+			calls++
+			gotPath, gotDur, gotErr = path, dur, err
+		},
+	}
+	if err := opts.Run(ctx, []Command{jobs}, []string{"jobs", "fail"}); !errors.Is(err, errFailed) {
+		t.Fatalf("want errFailed, got %v", err)
+	}
+	if calls != 1 || !slices.Equal(gotPath, []string{"jobs", "fail"}) || gotDur < time.Millisecond || !errors.Is(gotErr, errFailed) {
+		t.Fatalf("got %d calls with %q, %v and %v", calls, gotPath, gotDur, gotErr)
+	}
+
+	for _, args := range [][]string{{"help"}, {"jobs", "-bad"}} {
+		opts.Run(ctx, []Command{jobs}, args)
+	}
+	if calls != 1 {
+		t.Fatalf("want no calls for the built-in commands and parsing errors, got %d", calls)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	ctx := context.Background()
+
+	crash := NewCommand("crash", func(ctx context.Context, args []string) error {
+		panic("boom")
+	}, nil, "always panics")
+
+	opts := &Options{RecoverPanics: true}
+	err := opts.Run(ctx, []Command{crash}, []string{"crash"})
+	if err == nil || !strings.HasPrefix(err.Error(), "command panicked: boom\n") {
+		t.Fatalf("want panic error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "TestRecoverPanics") {
+		t.Fatalf("want stack trace in the error, got %v", err)
+	}
+}
+
+func TestPreserveTerminator(t *testing.T) {
+	ctx := context.Background()
+
+	exec := newTestCmd("exec")
+	exec.flags.Bool("background", false, "set to run in background")
+
+	args := []string{"exec", "-background", "--", "ls", "-l"}
+	if err := Run(ctx, []Command{exec}, args); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ls", "-l"}; !slices.Equal(exec.args, want) {
+		t.Fatalf("want %v, got %v", want, exec.args)
+	}
+
+	opts := &Options{PreserveTerminator: true}
+	if err := opts.Run(ctx, []Command{exec}, args); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"--", "ls", "-l"}; !slices.Equal(exec.args, want) {
+		t.Fatalf("want %v, got %v", want, exec.args)
+	}
+
+	opts.InterspersedFlags = true
+	if err := opts.Run(ctx, []Command{exec}, []string{"exec", "a", "-background", "--", "ls"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "--", "ls"}; !slices.Equal(exec.args, want) {
+		t.Fatalf("want %v, got %v", want, exec.args)
+	}
+
+	// the preserved "--" is not validated as a positional argument
+	cp := &exactArgsCmd{newTestCmd("cp")}
+	if err := opts.Run(ctx, []Command{cp}, []string{"cp", "--", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"--", "a", "b"}; !slices.Equal(cp.args, want) {
+		t.Fatalf("want %v, got %v", want, cp.args)
+	}
+	if err := opts.Run(ctx, []Command{cp}, []string{"cp", "a", "--", "b", "c"}); !errors.Is(err, ErrInvalidArgs) {
+		t.Fatalf("want ErrInvalidArgs, got %v", err)
+	}
+}
+
+func TestCombinedShortFlags(t *testing.T) {
+	ctx := context.Background()
+
+	ls := newTestCmd("ls")
+	all := ls.flags.Bool("a", false, "list all entries")
+	long := ls.flags.Bool("l", false, "use long format")
+	ls.flags.String("s", "name", "sort order")
+
+	if err := Run(ctx, []Command{ls}, []string{"ls", "-al"}); !errors.Is(err, ErrUnknownFlag) {
+		t.Fatalf("want unknown flag error by default, got %v", err)
+	}
+
+	opts := &Options{CombinedShortFlags: true}
+	if err := opts.Run(ctx, []Command{ls}, []string{"ls", "-al", "dir"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*all || !*long {
+		t.Fatalf("want -a and -l to be set, got %v and %v", *all, *long)
+	}
+	if want := []string{"dir"}; !slices.Equal(ls.args, want) {
+		t.Fatalf("want %v, got %v", want, ls.args)
+	}
+
+	if err := opts.Run(ctx, []Command{ls}, []string{"ls", "-as"}); !errors.Is(err, ErrBadFlagSyntax) {
+		t.Fatalf("want bad flag syntax error for non-boolean flag, got %v", err)
+	}
+	if err := opts.Run(ctx, []Command{ls}, []string{"ls", "-ax"}); !errors.Is(err, ErrUnknownFlag) {
+		t.Fatalf("want unknown flag error, got %v", err)
+	}
+}
+
+func TestDefaultCommand(t *testing.T) {
+	ctx := context.Background()
+
+	status := newTestCmd("status")
+	fetch := newTestCmd("fetch")
+	cmds := []Command{status, fetch}
+
+	opts := &Options{DefaultCommand: "status"}
+	if err := opts.Run(ctx, cmds, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	if status.args == nil {
+		t.Fatalf("want default command to run")
+	}
+
+	status.args = nil
+	if err := opts.Run(ctx, cmds, []string{"fetch", "now"}); err != nil {
+		t.Fatal(err)
+	}
+	if status.args != nil || !slices.Equal(fetch.args, []string{"now"}) {
+		t.Fatalf("want explicit command to override the default, got %q and %q", status.args, fetch.args)
+	}
+
+	path, _, _, err := opts.Resolve(cmds, []string{"--", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(path, []string{"status"}) {
+		t.Fatalf("want default command path, got %q", path)
+	}
+
+	bad := &Options{DefaultCommand: "missing"}
+	if err := bad.Run(ctx, cmds, nil); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("want os.ErrInvalid for undefined default command, got %v", err)
+	}
+}
+
+func TestCommandFromEnv(t *testing.T) {
+	ctx := context.Background()
+
+	status := newTestCmd("status")
+	start := newTestCmd("start")
+	server := NewGroup("server", "Server operations", start)
+	cmds := []Command{status, server}
+
+	t.Setenv("APP_COMMAND", "server  start")
+	opts := &Options{CommandFromEnv: "APP_COMMAND", DefaultCommand: "status"}
+	if err := opts.Run(ctx, cmds, []string{"--", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(start.args, []string{"a"}) || status.args != nil {
+		t.Fatalf("want command from the environment to run, got %q and %q", start.args, status.args)
+	}
+
+	start.args = nil
+	if err := opts.Run(ctx, cmds, []string{"status"}); err != nil {
+		t.Fatal(err)
+	}
+	if status.args == nil || start.args != nil {
+		t.Fatalf("want command-line to take precedence")
+	}
+
+	t.Setenv("APP_COMMAND", "server stop")
+	if err := opts.Run(ctx, cmds, nil); !errors.Is(err, ErrUnknownCommand) {
+		t.Fatalf("want ErrUnknownCommand, got %v", err)
+	}
+
+	t.Setenv("APP_COMMAND", "")
+	status.args = nil
+	if err := opts.Run(ctx, cmds, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	if status.args == nil {
+		t.Fatalf("want default command to run when the variable is empty")
+	}
+}
+
+func TestHelpOnErrorToStderr(t *testing.T) {
+	ctx := context.Background()
+
+	start := newTestCmd("start")
+	server := NewGroup("server", "Server operations", start)
+	cmds := []Command{server}
+
+	var stdout, stderr strings.Builder
+	opts := &Options{Stdout: &stdout, Stderr: &stderr, HelpOnErrorToStderr: true}
+	if err := opts.Run(ctx, cmds, []string{"server"}); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() != 0 || !strings.Contains(stderr.String(), "Usage:") {
+		t.Fatalf("want implicit help on stderr, got %q and %q", stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if err := opts.Run(ctx, cmds, []string{"help", "server"}); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.Len() != 0 || !strings.Contains(stdout.String(), "Usage:") {
+		t.Fatalf("want explicit help on stdout, got %q and %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestGNUFlags(t *testing.T) {
+	ctx := context.Background()
+
+	ls := newTestCmd("ls")
+	all := ls.flags.Bool("a", false, "list all entries")
+	long := ls.flags.Bool("l", false, "use long format")
+	ls.flags.Bool("al", false, "flag that looks like combined flags")
+	format := ls.flags.String("format", "text", "output format")
+
+	opts := &Options{GNUFlags: true}
+	if err := opts.Run(ctx, []Command{ls}, []string{"ls", "-a", "--format=json"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*all || *format != "json" {
+		t.Fatalf("want -a and --format to be set, got %v and %q", *all, *format)
+	}
+	for _, arg := range []string{"-format=json", "--a"} {
+		if err := opts.Run(ctx, []Command{ls}, []string{"ls", arg}); !errors.Is(err, ErrBadFlagSyntax) {
+			t.Errorf("Run(%q): want ErrBadFlagSyntax, got %v", arg, err)
+		}
+	}
+
+	*all = false
+	opts.CombinedShortFlags = true
+	if err := opts.Run(ctx, []Command{ls}, []string{"ls", "-al"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*all || !*long {
+		t.Fatalf("want -al as combined flags, got %v and %v", *all, *long)
+	}
+
+	if err := Run(ctx, []Command{ls}, []string{"ls", "-format=xml", "--a"}); err != nil {
+		t.Fatalf("want permissive flags by default, got %v", err)
+	}
+}
+
+func TestGetoptLong(t *testing.T) {
+	ctx := context.Background()
+
+	tar := newTestCmd("tar")
+	create := tar.flags.Bool("c", false, "create an archive")
+	verbose := tar.flags.Bool("v", false, "verbose output")
+	file := tar.flags.String("f", "", "archive file")
+	format := tar.flags.String("format", "", "archive format")
+	opts := &Options{GetoptLong: true}
+
+	tests := []struct {
+		args       []string
+		wantFile   string
+		wantFormat string
+		wantArgs   []string
+	}{
+		{[]string{"tar", "-cvf", "a.tar", "x"}, "a.tar", "", []string{"x"}},
+		{[]string{"tar", "-cvfa.tar", "x"}, "a.tar", "", []string{"x"}},
+		{[]string{"tar", "x", "-cv", "-fa=b", "--format=gnu"}, "a=b", "gnu", []string{"x"}},
+		{[]string{"tar", "-c", "-v", "-f", "a.tar", "--form", "gnu", "--", "-x"}, "a.tar", "gnu", []string{"-x"}},
+		{[]string{"tar", "+cv", "-f-", "x"}, "-", "", []string{"+cv", "x"}},
+		{[]string{"tar", "-cvformat=gnu"}, "ormat=gnu", "", nil},
+	}
+	for _, tt := range tests {
+		*create, *verbose, *file, *format = false, false, "", ""
+		if err := opts.Run(ctx, []Command{tar}, tt.args); err != nil {
+			t.Errorf("Run(%q): %v", tt.args, err)
+			continue
+		}
+		if *file != tt.wantFile || *format != tt.wantFormat || !slices.Equal(tar.args, tt.wantArgs) {
+			t.Errorf("Run(%q): got %q, %q and %q", tt.args, *file, *format, tar.args)
+		}
+		if *create != !strings.HasPrefix(tt.args[1], "+") || *verbose != *create {
+			t.Errorf("Run(%q): got -c %v and -v %v", tt.args, *create, *verbose)
+		}
+	}
+
+	for _, args := range [][]string{{"tar", "-cx"}, {"tar", "--f", "a.tar"}, {"tar", "-v=true"}, {"tar", "-cv=false"}} {
+		if err := opts.Run(ctx, []Command{tar}, args); !errors.Is(err, ErrBadFlagSyntax) {
+			t.Errorf("Run(%q): want ErrBadFlagSyntax, got %v", args, err)
+		}
+	}
+	if opts.GNUFlags || opts.InterspersedFlags {
+		t.Fatalf("want options not to be modified")
+	}
+}
+
+// colonFlags is a FlagParser for the "-name:value" flags.
+type colonFlags struct{}
+
+func (colonFlags) ParseFlag(arg string, lookup func(string) (bool, bool)) ([]ParsedFlag, error) {
+	name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), ":")
+	if defined, _ := lookup(name); !defined && name != "h" {
+		return nil, fmt.Errorf("unknown option %s: %w", arg, ErrUnknownFlag)
+	}
+	return []ParsedFlag{{Name: name, Value: value, HasValue: hasValue}}, nil
+}
+
+func TestFlagParser(t *testing.T) {
+	ctx := context.Background()
+
+	tar := newTestCmd("tar")
+	create := tar.flags.Bool("c", false, "create an archive")
+	file := tar.flags.String("file", "", "archive file")
+	opts := &Options{FlagParser: colonFlags{}, GetoptLong: true}
+
+	if err := opts.Run(ctx, []Command{tar}, []string{"tar", "-c", "-file:a=b", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if !*create || *file != "a=b" || !slices.Equal(tar.args, []string{"x"}) {
+		t.Fatalf("got %v, %q and %q", *create, *file, tar.args)
+	}
+	// flags without a value still take the next argument
+	if err := opts.Run(ctx, []Command{tar}, []string{"tar", "x", "--file", "c.tar"}); err != nil || *file != "c.tar" {
+		t.Fatalf("got %q and %v", *file, err)
+	}
+	if err := opts.Run(ctx, []Command{tar}, []string{"tar", "-x"}); !errors.Is(err, ErrUnknownFlag) {
+		t.Fatalf("want ErrUnknownFlag, got %v", err)
+	}
+}
+
+func TestStrictFlagValues(t *testing.T) {
+	ctx := context.Background()
+
+	grep := newTestCmd("grep")
+	pattern := grep.flags.String("e", "", "pattern")
+	verbose := grep.flags.Bool("v", false, "verbose")
+	cmds := []Command{grep}
+
+	if err := Run(ctx, cmds, []string{"grep", "-e", "-v"}); err != nil {
+		t.Fatal(err)
+	}
+	if *pattern != "-v" || *verbose {
+		t.Fatalf("want dash-prefixed value by default, got %q and %v", *pattern, *verbose)
+	}
+
+	opts := &Options{StrictFlagValues: true}
+	for _, args := range [][]string{{"grep", "-e", "-v"}, {"grep", "-e", "--v=true"}} {
+		if err := opts.Run(ctx, cmds, args); !errors.Is(err, ErrFlagNeedsArg) {
+			t.Errorf("Run(%q): want ErrFlagNeedsArg, got %v", args, err)
+		}
+	}
+	// Help flag is not consumed as the value, so the help is printed.
+	var stdout strings.Builder
+	opts.Stdout = &stdout
+	if err := opts.Run(ctx, cmds, []string{"grep", "-e", "-h"}); err != nil || !strings.HasPrefix(stdout.String(), "Usage: ") {
+		t.Fatalf("want help output, got %q and %v", stdout.String(), err)
+	}
+	if err := opts.Run(ctx, cmds, []string{"grep", "-e", "-x", "-e=-v", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if *pattern != "-v" {
+		t.Fatalf("want explicit dash-prefixed value, got %q", *pattern)
+	}
+	if err := opts.Run(ctx, cmds, []string{"grep", "-e", "-5"}); err != nil || *pattern != "-5" {
+		t.Fatalf("want negative number value, got %q and %v", *pattern, err)
+	}
+}
+
+func TestShowUsageOnError(t *testing.T) {
+	ctx := context.Background()
+
+	failed := errors.New("deploy failed")
+	deploy := NewCommand("deploy", func(ctx context.Context, args []string) error {
+		return failed
+	}, nil, "deploy the service")
+	start := newTestCmd("start")
+	server := NewGroup("server", "Server operations", start)
+	cmds := []Command{deploy, server}
+
+	var stdout, stderr strings.Builder
+	opts := &Options{ShowUsageOnError: IsUsageError, Stdout: &stdout, Stderr: &stderr, HelpOnErrorToStderr: true}
+	if err := opts.Run(ctx, cmds, []string{"server", "start", "-bad"}); !errors.Is(err, ErrUnknownFlag) {
+		t.Fatalf("want ErrUnknownFlag, got %v", err)
+	}
+	if out := stderr.String(); !strings.Contains(out, " server start <flags> <args>\n") || stdout.Len() != 0 {
+		t.Fatalf("want help for server start on stderr, got %q and %q", out, stdout.String())
+	}
+
+	stderr.Reset()
+	if err := opts.Run(ctx, cmds, []string{"deploy"}); !errors.Is(err, failed) {
+		t.Fatalf("want the command error, got %v", err)
+	}
+	if stderr.Len() != 0 {
+		t.Fatalf("want no help for the command errors, got %q", stderr.String())
+	}
+
+	opts.ShowUsageOnError = func(err error) bool { return errors.Is(err, failed) }
+	if err := opts.Run(ctx, cmds, []string{"deploy"}); !errors.Is(err, failed) {
+		t.Fatalf("want the command error, got %v", err)
+	}
+	if !strings.Contains(stderr.String(), "deploy the service") {
+		t.Fatalf("want help for deploy, got %q", stderr.String())
+	}
+
+	// usage from the error is for the command that failed, which may not be
+	// named in the command-line
+	stderr.Reset()
+	list := &noArgsCmd{newTestCmd("list")}
+	opts = &Options{ShowUsageOnError: IsUsageError, Stderr: &stderr, HelpOnErrorToStderr: true, DefaultCommand: "list"}
+	if err := opts.Run(ctx, []Command{list, deploy}, []string{"--", "extra"}); !errors.Is(err, ErrInvalidArgs) {
+		t.Fatalf("want ErrInvalidArgs, got %v", err)
+	}
+	if out := stderr.String(); !strings.HasPrefix(out, "Usage: cli.test list") {
+		t.Fatalf("want help for list, got %q", out)
+	}
+}
+
+func TestIsolateCommandLine(t *testing.T) {
+	ctx := context.Background()
+
+	start := newTestCmd("start")
+	cmds := []Command{start}
+
+	// Test binaries define the test.* flags on the flag.CommandLine.
+	if _, _, _, err := new(Options).Resolve(cmds, []string{"-test.short=false", "start"}); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{IsolateCommandLine: true}
+	if _, _, _, err := opts.Resolve(cmds, []string{"-test.short=false", "start"}); !errors.Is(err, ErrUnknownFlag) {
+		t.Fatalf("want ErrUnknownFlag for the flag.CommandLine flags, got %v", err)
+	}
+
+	var stdout strings.Builder
+	opts.Stdout = &stdout
+	if err := opts.Run(ctx, cmds, []string{"help", "start"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stdout.String(), "test.") {
+		t.Fatalf("want no flag.CommandLine flags in help, got %q", stdout.String())
+	}
+}
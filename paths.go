@@ -0,0 +1,120 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// PathCheck selects the checks for the path flags defined by [FileVar] and
+// [DirVar]. Checks can be combined with the bitwise OR operator.
+type PathCheck int
+
+const (
+	// PathMustExist requires the path to exist and to have the right type.
+	PathMustExist PathCheck = 1 << iota
+
+	// PathMustNotExist requires the path to not exist.
+	PathMustNotExist
+
+	// PathCreateParents creates the parent directories for the path, if they
+	// do not exist, right before the command runs, so that the command-lines
+	// that are only resolved or that fail do not change the file system.
+	PathCreateParents
+)
+
+type pathValue struct {
+	p     *string
+	def   string
+	dir   bool
+	check PathCheck
+}
+
+// reset restores the default value, which is never checked, so it does not
+// touch the file system.
+func (v *pathValue) reset() {
+	*v.p = v.def
+}
+
+func (v *pathValue) Set(s string) error {
+	if err := v.validate(s); err != nil {
+		return err
+	}
+	*v.p = s
+	return nil
+}
+
+func (v *pathValue) validate(s string) error {
+	if len(s) == 0 {
+		return errors.New("path cannot be empty")
+	}
+	fi, err := os.Stat(s)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	exists := err == nil
+	if v.check&PathMustExist != 0 {
+		if !exists {
+			return fmt.Errorf("path %q does not exist", s)
+		}
+		if v.dir && !fi.IsDir() {
+			return fmt.Errorf("path %q is not a directory", s)
+		}
+		if !v.dir && fi.IsDir() {
+			return fmt.Errorf("path %q is a directory", s)
+		}
+	}
+	if v.check&PathMustNotExist != 0 && exists {
+		return fmt.Errorf("path %q already exists", s)
+	}
+	return nil
+}
+
+// createParents creates the parent directories for the path with the
+// PathCreateParents check.
+func (v *pathValue) createParents() error {
+	if v.check&PathCreateParents == 0 {
+		return nil
+	}
+	return os.MkdirAll(filepath.Dir(*v.p), 0o755)
+}
+
+func (v *pathValue) Get() any { return *v.p }
+
+func (v *pathValue) String() string {
+	if v == nil || v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+// FileVar defines a file path flag with the specified name, default value and
+// usage string. The path from the command-line is checked as per the checks,
+// before it is stored in the string pointed to by p, so that the invalid paths
+// are reported as flag parsing errors. The default value is not checked.
+//
+// Example:
+//
+//	var input string
+//	cli.FileVar(fset, &input, "input", "", "input `file`", cli.PathMustExist)
+func FileVar(fset *flag.FlagSet, p *string, name, value, usage string, check PathCheck) {
+	*p = value
+	fset.Var(&pathValue{p: p, def: value, check: check}, name, usage)
+}
+
+// DirVar is similar to [FileVar], but defines a directory path flag. With the
+// PathMustExist check, the path must be a directory.
+//
+// Example:
+//
+//	var outdir string
+//	cli.DirVar(fset, &outdir, "output", ".", "output `dir`", cli.PathMustExist)
+func DirVar(fset *flag.FlagSet, p *string, name, value, usage string, check PathCheck) {
+	*p = value
+	fset.Var(&pathValue{p: p, def: value, dir: true, check: check}, name, usage)
+}
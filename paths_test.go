@@ -0,0 +1,70 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathFlags(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	build := newTestCmd("build")
+	var input, outdir, output string
+	FileVar(build.flags, &input, "input", "", "input file", PathMustExist)
+	DirVar(build.flags, &outdir, "outdir", ".", "output directory", PathMustExist)
+	FileVar(build.flags, &output, "output", "", "output file", PathMustNotExist|PathCreateParents)
+	cmds := []Command{build}
+
+	nested := filepath.Join(dir, "a", "b", "out.txt")
+
+	// Parent directories are not created unless the command runs.
+	if _, _, _, err := Resolve(cmds, []string{"build", "-output", nested}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(ctx, cmds, []string{"build", "-output", nested, "-input", dir}); !errors.Is(err, ErrInvalidFlagValue) {
+		t.Fatalf("want ErrInvalidFlagValue, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a")); !os.IsNotExist(err) {
+		t.Fatalf("want no parent directories before the command runs, got %v", err)
+	}
+
+	if err := Run(ctx, cmds, []string{"build", "-input", file, "-outdir", dir, "-output", nested}); err != nil {
+		t.Fatal(err)
+	}
+	if input != file || outdir != dir || output != nested {
+		t.Fatalf("want path values, got %q, %q and %q", input, outdir, output)
+	}
+	if _, err := os.Stat(filepath.Dir(nested)); err != nil {
+		t.Fatalf("want parent directories to be created, got %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"build", "-input", filepath.Join(dir, "missing")},
+		{"build", "-input", dir},
+		{"build", "-outdir", file},
+		{"build", "-output", file},
+	} {
+		if err := Run(ctx, cmds, args); !errors.Is(err, ErrInvalidFlagValue) {
+			t.Errorf("Run(%q): want ErrInvalidFlagValue, got %v", args, err)
+		}
+	}
+
+	// Defaults are restored without the checks.
+	if err := ResetFlags(build.flags); err != nil {
+		t.Fatal(err)
+	}
+	if input != "" || outdir != "." || output != "" {
+		t.Fatalf("want default path values, got %q, %q and %q", input, outdir, output)
+	}
+}
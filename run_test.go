@@ -4,10 +4,13 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -91,3 +94,161 @@ func TestRun(t *testing.T) {
 		}
 	}
 }
+
+func TestFlagValues(t *testing.T) {
+	ctx := context.Background()
+
+	get := newTestCmd("get")
+	filter := get.flags.String("filter", "default", "filter expression")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"get", "--filter="}, ""},
+		{[]string{"get", "--filter", ""}, ""},
+		{[]string{"get", "-filter="}, ""},
+		{[]string{"get", "--filter=key=val"}, "key=val"},
+		{[]string{"get", "--filter", "key=val"}, "key=val"},
+		{[]string{"get", "--filter=a b c"}, "a b c"},
+		{[]string{"get", "--filter", "a b c"}, "a b c"},
+		{[]string{"get", "--filter=-x"}, "-x"},
+		{[]string{"get", "--filter=--"}, "--"},
+	}
+	for _, tt := range tests {
+		*filter = "default"
+		if err := Run(ctx, []Command{get}, tt.args); err != nil {
+			t.Errorf("Run(%q): got error %v", tt.args, err)
+			continue
+		}
+		if *filter != tt.want {
+			t.Errorf("Run(%q): got %q, want %q", tt.args, *filter, tt.want)
+		}
+		if len(get.args) != 0 {
+			t.Errorf("Run(%q): got args %q, want none", tt.args, get.args)
+		}
+	}
+}
+
+func TestNegativeNumbers(t *testing.T) {
+	ctx := context.Background()
+
+	seek := newTestCmd("seek")
+	offset := seek.flags.Float64("offset", 0, "seek offset")
+	seek.flags.Bool("1", false, "flag that looks like a number")
+
+	for _, arg := range []string{"-123", "-1.5", "-0x10"} {
+		if err := Run(ctx, []Command{seek}, []string{"seek", arg, "x"}); err != nil {
+			t.Errorf("Run(%q): got error %v", arg, err)
+			continue
+		}
+		if want := []string{arg, "x"}; !slices.Equal(seek.args, want) {
+			t.Errorf("Run(%q): got args %q, want %q", arg, seek.args, want)
+		}
+	}
+
+	if err := Run(ctx, []Command{seek}, []string{"seek", "-offset", "-1.5"}); err != nil {
+		t.Fatal(err)
+	}
+	if *offset != -1.5 {
+		t.Fatalf("want -1.5 offset, got %v", *offset)
+	}
+
+	// defined flags take precedence over the negative numbers
+	if err := Run(ctx, []Command{seek}, []string{"seek", "-1", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"x"}; !slices.Equal(seek.args, want) {
+		t.Fatalf("want %q, got %q", want, seek.args)
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	valid := []string{"run", "db-scan", "job_pause", "v2"}
+	for _, name := range valid {
+		if err := CheckName(name); err != nil {
+			t.Errorf("CheckName(%q): got error %v, want nil", name, err)
+		}
+		if NewCommand(name, printVersion, nil, "") == nil {
+			t.Errorf("NewCommand(%q): got nil, want a command", name)
+		}
+	}
+	invalid := []string{"", "-run", "db scan", "db\tscan", "db/scan", `db\scan`}
+	for _, name := range invalid {
+		if err := CheckName(name); err == nil {
+			t.Errorf("CheckName(%q): got nil, want an error", name)
+		}
+		if NewCommand(name, printVersion, nil, "") != nil {
+			t.Errorf("NewCommand(%q): got a command, want nil", name)
+		}
+		if NewGroup(name, "") != nil {
+			t.Errorf("NewGroup(%q): got a command, want nil", name)
+		}
+	}
+
+	ctx := context.Background()
+	if err := Run(ctx, []Command{newTestCmd("db scan")}, []string{"db"}); err == nil {
+		t.Errorf("Run: got nil, want an error for invalid command name")
+	}
+	if err := Run(ctx, []Command{NewCommand("", printVersion, nil, "")}, []string{"db"}); err == nil {
+		t.Errorf("Run: got nil, want an error for nil command")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	scan := newTestCmd("scan")
+	format := scan.flags.String("format", "text", "output format")
+	db := NewGroup("db", "manage database", scan)
+	cmds := []Command{db}
+
+	path, args, special, err := Resolve(cmds, []string{"db", "scan", "-format=json", "prefix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(path, []string{"db", "scan"}) || !slices.Equal(args, []string{"prefix"}) || special != "" {
+		t.Fatalf("got path %q, args %q, special %q", path, args, special)
+	}
+	if *format != "json" {
+		t.Fatalf("want format json, got %q", *format)
+	}
+	if scan.args != nil {
+		t.Fatalf("want scan command not to be executed")
+	}
+
+	path, _, special, err = Resolve(cmds, []string{"help", "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(path, []string{"db"}) || special != "help" {
+		t.Fatalf("got path %q, special %q", path, special)
+	}
+
+	if _, _, _, err := Resolve(cmds, []string{"db", "restart"}); !errors.Is(err, ErrUnknownCommand) {
+		t.Fatalf("want ErrUnknownCommand, got %v", err)
+	}
+}
+
+func TestRunCapture(t *testing.T) {
+	ctx := context.Background()
+
+	scan := newTestCmd("scan")
+	fetch := &deprecatedCmd{newTestCmd("fetch")}
+	db := NewGroup("db", "manage database", scan, fetch)
+	cmds := []Command{db}
+
+	stdout, stderr, err := RunCapture(ctx, cmds, []string{"help", "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "Usage:") || !strings.Contains(stdout, "scan") || len(stderr) != 0 {
+		t.Fatalf("want help in stdout only, got %q and %q", stdout, stderr)
+	}
+
+	stdout, stderr, err = RunCapture(ctx, cmds, []string{"db", "fetch"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stdout) != 0 || !strings.Contains(stderr, "deprecated") {
+		t.Fatalf("want deprecation warning in stderr only, got %q and %q", stdout, stderr)
+	}
+}
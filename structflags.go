@@ -0,0 +1,148 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RegisterFlags defines flags in the flag set for the fields of the struct
+// pointed to by v, as described by the "cli" struct tags of the fields. Tag
+// starts with the flag name, followed by the optional "default=" and
+// "usage=" settings. Usage setting must be the last, so that it can contain
+// commas. Current field value is the default value when the default setting
+// is not given. Fields of the int, string, bool and time.Duration types are
+// supported. Fields without the tag are ignored, except for the embedded
+// structs, whose fields are registered recursively.
+//
+// Returns os.ErrInvalid if v is not a pointer to a struct, or if a tag or a
+// default value is invalid, and os.ErrExist if a flag is already defined.
+//
+// Example:
+//
+//	type ClientFlags struct {
+//	    Port    int           `cli:"connect-port,default=10000,usage=TCP port number for the api endpoint"`
+//	    Host    string        `cli:"connect-host,default=127.0.0.1,usage=api endpoint host"`
+//	    Timeout time.Duration `cli:"http-timeout,default=30s,usage=http client timeout"`
+//	}
+//
+//	var cf ClientFlags
+//	if err := cli.RegisterFlags(fset, &cf); err != nil {
+//	    return err
+//	}
+func RegisterFlags(fset *flag.FlagSet, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("input must be a pointer to a struct: %w", os.ErrInvalid)
+	}
+	return registerFields(fset, rv.Elem())
+}
+
+func registerFields(fset *flag.FlagSet, sv reflect.Value) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field, fv := st.Field(i), sv.Field(i)
+		tag, ok := field.Tag.Lookup("cli")
+		if !ok {
+			if field.Anonymous && field.IsExported() && fv.Kind() == reflect.Struct {
+				if err := registerFields(fset, fv); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if tag == "-" {
+			continue
+		}
+		name, def, hasDef, usage, err := parseFlagTag(tag)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if !field.IsExported() {
+			return fmt.Errorf("field %s: flag field must be exported: %w", field.Name, os.ErrInvalid)
+		}
+		if fset.Lookup(name) != nil {
+			return fmt.Errorf("field %s: flag -%s is already defined: %w", field.Name, name, os.ErrExist)
+		}
+		if err := registerField(fset, fv, name, def, hasDef, usage); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// parseFlagTag splits a "cli" struct tag into the flag name, default value and
+// the usage string.
+func parseFlagTag(tag string) (name, def string, hasDef bool, usage string, err error) {
+	name, rest, _ := strings.Cut(tag, ",")
+	if len(name) == 0 || strings.HasPrefix(name, "-") || strings.Contains(name, "=") {
+		return "", "", false, "", fmt.Errorf("invalid flag name in tag %q: %w", tag, os.ErrInvalid)
+	}
+	for len(rest) > 0 {
+		if s, ok := strings.CutPrefix(rest, "usage="); ok {
+			usage = s
+			break
+		}
+		var item string
+		item, rest, _ = strings.Cut(rest, ",")
+		key, value, ok := strings.Cut(item, "=")
+		if !ok || key != "default" {
+			return "", "", false, "", fmt.Errorf("invalid setting %q in tag %q: %w", item, tag, os.ErrInvalid)
+		}
+		def, hasDef = value, true
+	}
+	return name, def, hasDef, usage, nil
+}
+
+func registerField(fset *flag.FlagSet, fv reflect.Value, name, def string, hasDef bool, usage string) error {
+	invalid := func(err error) error {
+		return fmt.Errorf("invalid default value %q for flag -%s: %v: %w", def, name, err, os.ErrInvalid)
+	}
+	switch fv.Type() {
+	case reflect.TypeFor[time.Duration]():
+		p := fv.Addr().Interface().(*time.Duration)
+		if hasDef {
+			d, err := time.ParseDuration(def)
+			if err != nil {
+				return invalid(err)
+			}
+			*p = d
+		}
+		fset.DurationVar(p, name, *p, usage)
+	case reflect.TypeFor[int]():
+		p := fv.Addr().Interface().(*int)
+		if hasDef {
+			n, err := strconv.Atoi(def)
+			if err != nil {
+				return invalid(err)
+			}
+			*p = n
+		}
+		fset.IntVar(p, name, *p, usage)
+	case reflect.TypeFor[string]():
+		p := fv.Addr().Interface().(*string)
+		if hasDef {
+			*p = def
+		}
+		fset.StringVar(p, name, *p, usage)
+	case reflect.TypeFor[bool]():
+		p := fv.Addr().Interface().(*bool)
+		if hasDef {
+			b, err := strconv.ParseBool(def)
+			if err != nil {
+				return invalid(err)
+			}
+			*p = b
+		}
+		fset.BoolVar(p, name, *p, usage)
+	default:
+		return fmt.Errorf("unsupported flag type %s: %w", fv.Type(), os.ErrInvalid)
+	}
+	return nil
+}
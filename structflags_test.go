@@ -0,0 +1,78 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"testing"
+	"time"
+)
+
+type DBFlags struct {
+	DBPath string `cli:"db-path,default=/db,usage=path to db api handler"`
+}
+
+type clientFlags struct {
+	Port    int           `cli:"connect-port,default=10000,usage=TCP port, for the api endpoint"`
+	Host    string        `cli:"connect-host,usage=api endpoint host"`
+	Timeout time.Duration `cli:"http-timeout,default=30s"`
+	Verbose bool          `cli:"verbose,default=true"`
+	Ignored string        `cli:"-"`
+	Other   int
+
+	DBFlags
+}
+
+func TestRegisterFlags(t *testing.T) {
+	ctx := context.Background()
+
+	list := newTestCmd("list")
+	cf := clientFlags{Host: "127.0.0.1"}
+	if err := RegisterFlags(list.flags, &cf); err != nil {
+		t.Fatal(err)
+	}
+	if cf.Port != 10000 || cf.Host != "127.0.0.1" || cf.Timeout != 30*time.Second || !cf.Verbose || cf.DBPath != "/db" {
+		t.Fatalf("want default values, got %+v", cf)
+	}
+	if f := list.flags.Lookup("connect-port"); f == nil || f.Usage != "TCP port, for the api endpoint" || f.DefValue != "10000" {
+		t.Fatalf("want connect-port flag with usage and default, got %+v", f)
+	}
+	if list.flags.Lookup("Ignored") != nil || list.flags.Lookup("Other") != nil {
+		t.Fatalf("want untagged fields to be ignored")
+	}
+
+	args := []string{"list", "-connect-port=8080", "-connect-host=db", "-http-timeout=1m", "-verbose=false", "-db-path=/x"}
+	if err := Run(ctx, []Command{list}, args); err != nil {
+		t.Fatal(err)
+	}
+	want := clientFlags{Port: 8080, Host: "db", Timeout: time.Minute, DBFlags: DBFlags{DBPath: "/x"}}
+	if cf != want {
+		t.Fatalf("got %+v, want %+v", cf, want)
+	}
+
+	if err := RegisterFlags(list.flags, &cf); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("want os.ErrExist for duplicate flags, got %v", err)
+	}
+	for _, v := range []any{
+		cf,
+		&struct {
+			N int `cli:"n,default=x"`
+		}{},
+		&struct {
+			F float64 `cli:"f"`
+		}{},
+		&struct {
+			N int `cli:"n,value=1"`
+		}{},
+		&struct {
+			n int `cli:"n"`
+		}{},
+	} {
+		if err := RegisterFlags(flag.NewFlagSet("test", flag.ContinueOnError), v); !errors.Is(err, os.ErrInvalid) {
+			t.Errorf("RegisterFlags(%T): want os.ErrInvalid, got %v", v, err)
+		}
+	}
+}
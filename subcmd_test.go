@@ -4,9 +4,12 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"os"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -77,7 +80,7 @@ func TestSubcommandExecution(t *testing.T) {
 			args:     []string{"server", "restart"},
 			wantCmd:  "",
 			wantArgs: nil,
-			wantErr:  "command not defined: restart",
+			wantErr:  "command not defined: restart (available: start, stop)",
 		},
 	}
 
@@ -115,3 +118,353 @@ func TestSubcommandExecution(t *testing.T) {
 		})
 	}
 }
+
+func TestGroupFlags(t *testing.T) {
+	ctx := context.Background()
+
+	var startArgs []string
+	startCmd := NewCommand("start", func(ctx context.Context, args []string) error {
+		startArgs = args
+		return nil
+	}, nil, "Start the server")
+
+	var fset flag.FlagSet
+	config := fset.String("config", "", "server config file")
+	serverGroup := NewGroupWithFlags("server", "Server operations", &fset, startCmd)
+
+	if err := Run(ctx, []Command{serverGroup}, []string{"server", "--config=x", "start", "arg"}); err != nil {
+		t.Fatal(err)
+	}
+	if *config != "x" {
+		t.Errorf("Run: got config %q, want %q", *config, "x")
+	}
+	if !reflect.DeepEqual(startArgs, []string{"arg"}) {
+		t.Errorf("Run: got args %v, want [arg]", startArgs)
+	}
+
+	// Group flags are inherited by the subcommands.
+	if err := Run(ctx, []Command{serverGroup}, []string{"server", "start", "-config", "y"}); err != nil {
+		t.Fatal(err)
+	}
+	if *config != "y" {
+		t.Errorf("Run: got config %q, want %q", *config, "y")
+	}
+}
+
+func TestFlagOrdering(t *testing.T) {
+	ctx := context.Background()
+
+	gflags := flag.NewFlagSet("global", flag.ContinueOnError)
+	verbose := gflags.Bool("verbose", false, "enable verbose output")
+
+	start := newTestCmd("start")
+	force := start.flags.Bool("force", false, "force start")
+	var sflags flag.FlagSet
+	config := sflags.String("config", "", "server config file")
+	server := NewGroupWithFlags("server", "Server operations", &sflags, start)
+	cmds := []Command{server}
+
+	opts := &Options{GlobalFlags: gflags}
+	args := []string{"--verbose", "server", "--config=x", "start", "--force"}
+	if err := opts.Run(ctx, cmds, args); err != nil {
+		t.Fatal(err)
+	}
+	if !*verbose || *config != "x" || !*force {
+		t.Fatalf("want all flags set, got %v, %q and %v", *verbose, *config, *force)
+	}
+
+	err := opts.Run(ctx, cmds, []string{"server", "--force", "start"})
+	if want := "server: flag -force belongs to 'server start'; place it after the command"; !errors.Is(err, ErrUnknownFlag) || err.Error() != want {
+		t.Fatalf("want flag ordering error %q, got %v", want, err)
+	}
+
+	err = opts.Run(ctx, cmds, []string{"-force", "server", "start"})
+	if want := "flag -force belongs to 'server start'; place it after the command"; !errors.Is(err, ErrUnknownFlag) || err.Error() != want {
+		t.Fatalf("want flag ordering error %q, got %v", want, err)
+	}
+}
+
+func TestAddCommand(t *testing.T) {
+	ctx := context.Background()
+
+	group := NewGroup("plugin", "Plugin commands")
+	var ran bool
+	hello := NewCommand("hello", func(ctx context.Context, args []string) error {
+		ran = true
+		return nil
+	}, nil, "Say hello")
+
+	if err := AddCommand(group, hello); err != nil {
+		t.Fatal(err)
+	}
+	if err := Run(ctx, []Command{group}, []string{"plugin", "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Errorf("AddCommand: added command did not run")
+	}
+
+	if err := AddCommand(group, NewCommand("hello", nil, nil, "")); !errors.Is(err, os.ErrExist) {
+		t.Errorf("AddCommand: got %v, want os.ErrExist for duplicate", err)
+	}
+	if err := AddCommand(group, NewCommand("Hello", nil, nil, "")); !errors.Is(err, os.ErrExist) {
+		t.Errorf("AddCommand: got %v, want os.ErrExist for case-folded duplicate", err)
+	}
+	if err := AddCommand(group, nil); err == nil {
+		t.Errorf("AddCommand: got nil, want error for nil command")
+	}
+	if err := AddCommand(hello, group); !errors.Is(err, os.ErrInvalid) {
+		t.Errorf("AddCommand: got %v, want os.ErrInvalid for non-group", err)
+	}
+}
+
+func TestLazyCommand(t *testing.T) {
+	ctx := context.Background()
+
+	built := make(map[string]int)
+	lazy := func(name string) Command {
+		return NewLazyCommand(name, "Lazy "+name, func() Command {
+			built[name]++
+			fset := flag.NewFlagSet(name, flag.ContinueOnError)
+			fset.Int("level", 0, "index level")
+			return NewCommand(name, func(ctx context.Context, args []string) error {
+				return nil
+			}, fset, "")
+		})
+	}
+	index := lazy("index")
+	reindex := lazy("reindex")
+	group := NewGroup("db", "Database commands", lazy("backup"), NewGroup("admin", "Admin", index, reindex))
+
+	if err := Run(ctx, []Command{group}, []string{"db", "admin", "index", "-level=2"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(built, map[string]int{"index": 1}) {
+		t.Errorf("NewLazyCommand: got built %v, want only index", built)
+	}
+	if err := Run(ctx, []Command{group}, []string{"db", "admin", "index"}); err != nil {
+		t.Fatal(err)
+	}
+	if built["index"] != 1 {
+		t.Errorf("NewLazyCommand: got %d builds, want 1", built["index"])
+	}
+
+	// Listing the subcommands uses the lazy name and purpose.
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout}
+	if err := opts.Run(ctx, []Command{group}, []string{"help", "db", "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "reindex          Lazy reindex") || built["reindex"] != 0 {
+		t.Errorf("NewLazyCommand: got help %q with builds %v", stdout.String(), built)
+	}
+}
+
+func TestLazyGroup(t *testing.T) {
+	ctx := context.Background()
+
+	builds := 0
+	list := newTestCmd("list")
+	jobs := NewLazyCommand("jobs", "Manage jobs", func() Command {
+		builds++
+		return NewGroup("jobs", "", list)
+	})
+	server := NewGroup("server", "Server operations", newTestCmd("start"))
+	cmds := []Command{server, jobs, newTestCmd("version")}
+
+	// The lazy group is listed with the commands, before the groups, since it
+	// is not constructed for the listing.
+	stdout, _, err := RunCapture(ctx, cmds, []string{"help"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j, v, s := strings.Index(stdout, "jobs"), strings.Index(stdout, "version"), strings.Index(stdout, "server"); j < 0 || j > v || v > s || builds != 0 {
+		t.Errorf("NewLazyCommand: got help %q with %d builds", stdout, builds)
+	}
+
+	// The help for the lazy group itself lists its subcommands.
+	stdout, _, err = RunCapture(ctx, cmds, []string{"help", "jobs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "list") || builds != 1 {
+		t.Errorf("NewLazyCommand: got help %q with %d builds", stdout, builds)
+	}
+	if err := Run(ctx, cmds, []string{"jobs", "list", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(list.args, []string{"a"}) {
+		t.Errorf("NewLazyCommand: got args %q, want [a]", list.args)
+	}
+}
+
+type twoValueCmd struct {
+	calls int
+	key   string
+	args  []string
+}
+
+func (c *twoValueCmd) Command() (*flag.FlagSet, CmdFunc) {
+	c.calls++
+	fset := flag.NewFlagSet("list", flag.ContinueOnError)
+	fset.StringVar(&c.key, "key", "", "key to list")
+	return fset, func(ctx context.Context, args []string) error {
+		c.args = args
+		return nil
+	}
+}
+
+func (c *twoValueCmd) Purpose() string { return "List the keys" }
+
+func (c *twoValueCmd) ValidateArgs(args []string) error { return MaximumNArgs(1)(args) }
+
+func TestAdaptCommand(t *testing.T) {
+	ctx := context.Background()
+
+	list := new(twoValueCmd)
+	cmd := AdaptCommand(list)
+	if name := cmdName(cmd); name != "list" {
+		t.Fatalf("want command name from the flag set, got %q", name)
+	}
+
+	if err := Run(ctx, []Command{cmd}, []string{"list", "-key=a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if list.key != "a" || !slices.Equal(list.args, []string{"b"}) || list.calls != 1 {
+		t.Fatalf("got key %q, args %q and %d calls", list.key, list.args, list.calls)
+	}
+	if err := Run(ctx, []Command{cmd}, []string{"list", "b", "c"}); !errors.Is(err, ErrInvalidArgs) {
+		t.Fatalf("want ErrInvalidArgs from the adapted ValidateArgs, got %v", err)
+	}
+
+	stdout, _, err := RunCapture(ctx, []Command{cmd}, []string{"help"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout, "List the keys") {
+		t.Fatalf("want the adapted purpose in help, got %q", stdout)
+	}
+
+	if AdaptCommand(nil) != nil {
+		t.Fatal("want nil for a nil command")
+	}
+}
+
+type PortFlags struct{ Port int }
+
+func (f *PortFlags) SetFlags(fset *flag.FlagSet) {
+	fset.IntVar(&f.Port, "port", 10000, "TCP port number")
+}
+
+type HostFlags struct{ Host string }
+
+func (f *HostFlags) SetFlags(fset *flag.FlagSet) {
+	fset.StringVar(&f.Host, "host", "localhost", "host name")
+}
+
+type statusCmd struct {
+	HostFlags
+	*PortFlags
+
+	args []string
+}
+
+func (c *statusCmd) Purpose() string { return "Print the server status" }
+
+func (c *statusCmd) Run(ctx context.Context, args []string) error {
+	c.args = args
+	return nil
+}
+
+func TestFromFunc(t *testing.T) {
+	ctx := context.Background()
+
+	var force bool
+	var got []string
+	cmd := FromFunc("clean", "Remove build outputs", func(ctx context.Context, args []string) error {
+		got = args
+		return nil
+	}, func(fset *flag.FlagSet) {
+		fset.BoolVar(&force, "force", false, "remove without confirmation")
+	})
+	if err := Run(ctx, []Command{cmd}, []string{"clean", "-force", "out"}); err != nil {
+		t.Fatal(err)
+	}
+	if !force || !slices.Equal(got, []string{"out"}) {
+		t.Fatalf("got force %v and args %q", force, got)
+	}
+	if getPurpose(cmd) != "Remove build outputs" {
+		t.Fatalf("got purpose %q", getPurpose(cmd))
+	}
+	if FromFunc("clean", "", nil, nil) != nil || FromFunc("-clean", "", func(context.Context, []string) error { return nil }, nil) != nil {
+		t.Fatal("want nil for invalid inputs")
+	}
+}
+
+func TestSharedFlags(t *testing.T) {
+	ctx := context.Background()
+
+	common := flag.NewFlagSet("common", flag.ContinueOnError)
+	addr := common.String("addr", "localhost", "server address")
+	var ran []string
+	newCmd := func(name string) Command {
+		return NewCommandWithSharedFlags(name, func(ctx context.Context, args []string) error {
+			ran = append(ran, strings.Join(CommandPath(ctx), " ")+"@"+*addr)
+			return nil
+		}, common, "Access a key")
+	}
+	kv := NewGroup("kv", "key-value operations", newCmd("get"), newCmd("put"))
+
+	for _, args := range [][]string{{"kv", "get", "-addr=a"}, {"kv", "put", "-addr=b"}} {
+		if err := Run(ctx, []Command{kv}, args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"kv get@a", "kv put@b"}; !slices.Equal(ran, want) {
+		t.Fatalf("want %q, got %q", want, ran)
+	}
+	if common.Name() != "common" {
+		t.Fatalf("want flag set name unchanged, got %q", common.Name())
+	}
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout}
+	if err := opts.Run(ctx, []Command{kv}, []string{"help", "kv", "put"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), " kv put <flags>") {
+		t.Fatalf("want command name in usage, got %q", stdout.String())
+	}
+	if NewCommandWithSharedFlags("get", nil, nil, "") != nil {
+		t.Fatal("want nil for a nil flag set")
+	}
+}
+
+func TestFromStruct(t *testing.T) {
+	ctx := context.Background()
+
+	status := &statusCmd{PortFlags: new(PortFlags)}
+	cmd := FromStruct("status", status)
+	if err := Run(ctx, []Command{cmd}, []string{"status", "-host=db", "-port=8080", "now"}); err != nil {
+		t.Fatal(err)
+	}
+	if status.Host != "db" || status.Port != 8080 || !slices.Equal(status.args, []string{"now"}) {
+		t.Fatalf("got host %q, port %d and args %q", status.Host, status.Port, status.args)
+	}
+	if getPurpose(cmd) != "Print the server status" {
+		t.Fatalf("got purpose %q", getPurpose(cmd))
+	}
+
+	// A SetFlags method on the struct replaces the embedded ones.
+	flags := FromStruct("flags", &struct {
+		statusCmd
+		PortFlags
+	}{})
+	if _, fs, _ := flags.Command(); fs.Lookup("port") == nil || fs.Lookup("host") != nil {
+		t.Fatal("want only the flags from the struct's SetFlags method")
+	}
+
+	if FromStruct("status", statusCmd{}) != nil || FromStruct("status", new(PortFlags)) != nil {
+		t.Fatal("want nil for a non-pointer or a struct without Run")
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"format", "format", 0},
+		{"formt", "format", 1},
+		{"force", "format", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q): got %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFlagSuggestion(t *testing.T) {
+	ctx := context.Background()
+
+	list := newTestCmd("list")
+	list.flags.String("format", "json", "list output format")
+	list.flags.Bool("force", false, "force listing")
+	jobs := NewGroup("jobs", "manage jobs", list)
+
+	err := Run(ctx, []Command{jobs}, []string{"jobs", "list", "-formt=text"})
+	if err == nil || !strings.Contains(err.Error(), "did you mean -format?") {
+		t.Fatalf("want flag suggestion, got %v", err)
+	}
+
+	err = Run(ctx, []Command{jobs}, []string{"jobs", "list", "-xyz"})
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Fatalf("want no flag suggestion, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// commandNode describes a command and its subcommands in the command tree.
+type commandNode struct {
+	Name        string         `json:"name"`
+	Purpose     string         `json:"purpose,omitempty"`
+	Group       bool           `json:"group,omitempty"`
+	Subcommands []*commandNode `json:"subcommands,omitempty"`
+}
+
+// getCommandTree returns the command tree rooted at the given command. Parent
+// is the command path to the command, which is used with the command filter.
+// Subcommands are ordered the same as in the help output.
+func getCommandTree(opts *Options, parent []string, name string, c Command) *commandNode {
+	node := &commandNode{
+		Name:    name,
+		Purpose: getPurpose(c),
+	}
+	// lazy commands must be constructed to walk their subcommands
+	if lc, ok := c.(*lazyCmd); ok {
+		c = lc.get()
+	}
+	gc, ok := c.(*groupCmd)
+	if !ok {
+		return node
+	}
+	node.Group = true
+
+	for _, section := range orderSubcommands(opts, gc.subcmds) {
+		for _, sub := range section {
+			subname := cmdName(sub)
+			path := append(parent[:len(parent):len(parent)], subname)
+			if !opts.isAllowed(path) {
+				continue
+			}
+			node.Subcommands = append(node.Subcommands, getCommandTree(opts, path, subname, sub))
+		}
+	}
+	return node
+}
+
+// printCommandsJSON prints the command tree rooted at the last command in the
+// command path in JSON format.
+func (gc *groupCmd) printCommandsJSON(w io.Writer, cmdpath []*cmdData) error {
+	last := cmdpath[len(cmdpath)-1]
+	name := last.name
+	if len(cmdpath) == 1 {
+		_, name = filepath.Split(last.fset.Name())
+	}
+	tree := getCommandTree(gc.opts, cmdNames(cmdpath), name, last.cmd)
+	js, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(js, '\n'))
+	return err
+}
+
+// printCommandsAll prints all commands in the subtree of the last command in
+// the command path with their full command paths, indented by the depth.
+func (gc *groupCmd) printCommandsAll(w io.Writer, cmdpath []*cmdData) error {
+	last := cmdpath[len(cmdpath)-1]
+	tree := getCommandTree(gc.opts, cmdNames(cmdpath), last.name, last.cmd)
+
+	var pairs [][2]string
+	var visit func(prefix []string, depth int, node *commandNode)
+	visit = func(prefix []string, depth int, node *commandNode) {
+		for _, sub := range node.Subcommands {
+			path := append(prefix[:len(prefix):len(prefix)], sub.Name)
+			name := strings.Repeat("  ", depth) + strings.Join(path, " ")
+			pairs = append(pairs, [2]string{name, sub.Purpose})
+			visit(path, depth+1, sub)
+		}
+	}
+	visit(cmdNames(cmdpath), 0, tree)
+	printCommandList(w, gc.opts.palette(w), pairs)
+	return nil
+}
+
+// printLeafCommands prints the full paths and purposes for all leaf commands
+// in the subtree of the last command in the command path, as a flat index.
+func (gc *groupCmd) printLeafCommands(w io.Writer, cmdpath []*cmdData) error {
+	last := cmdpath[len(cmdpath)-1]
+	tree := getCommandTree(gc.opts, cmdNames(cmdpath), last.name, last.cmd)
+
+	var pairs [][2]string
+	var visit func(prefix []string, node *commandNode)
+	visit = func(prefix []string, node *commandNode) {
+		for _, sub := range node.Subcommands {
+			path := append(prefix[:len(prefix):len(prefix)], sub.Name)
+			if sub.Group {
+				visit(path, sub)
+				continue
+			}
+			pairs = append(pairs, [2]string{strings.Join(path, " "), sub.Purpose})
+		}
+	}
+	visit(cmdNames(cmdpath), tree)
+
+	pal := gc.opts.palette(w)
+	fmt.Fprintf(w, "%s\n", pal.header("Commands:"))
+	printCommandList(w, pal, pairs)
+	return nil
+}
+
+// PrintTree prints the commands and their subcommands as a tree, along with
+// their purposes. Subcommands are ordered the same as in the help output.
+//
+// Example:
+//
+//	cli.PrintTree(os.Stdout, cmds)
+//	// ├── version  Print version
+//	// └── server  Server operations
+//	//     ├── start  Start the server
+//	//     └── stop  Stop the server
+func PrintTree(w io.Writer, cmds []Command) {
+	root := getCommandTree(new(Options), nil, "", &groupCmd{subcmds: cmds})
+	printTreeNodes(w, "", root.Subcommands)
+}
+
+func printTreeNodes(w io.Writer, indent string, nodes []*commandNode) {
+	for i, node := range nodes {
+		branch, next := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, next = "└── ", "    "
+		}
+		if len(node.Purpose) > 0 {
+			fmt.Fprintf(w, "%s%s%s  %s\n", indent, branch, node.Name, node.Purpose)
+		} else {
+			fmt.Fprintf(w, "%s%s%s\n", indent, branch, node.Name)
+		}
+		printTreeNodes(w, indent+next, node.Subcommands)
+	}
+}
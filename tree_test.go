@@ -0,0 +1,141 @@
+// Copyright (c) 2025 Visvasity LLC
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCommandsJSON(t *testing.T) {
+	ctx := context.Background()
+
+	start := NewCommand("start", nil, nil, "Start the server")
+	stop := NewCommand("stop", nil, nil, "Stop the server")
+	server := NewGroup("server", "Server operations", stop, start)
+	version := NewCommand("version", nil, nil, "Print version")
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout}
+	if err := opts.Run(ctx, []Command{server, version}, []string{"commands", "--json"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var tree commandNode
+	if err := json.Unmarshal([]byte(stdout.String()), &tree); err != nil {
+		t.Fatal(err)
+	}
+	if !tree.Group || len(tree.Subcommands) != 2 {
+		t.Fatalf("want root group with two subcommands, got %+v", tree)
+	}
+	if v := tree.Subcommands[0]; v.Name != "version" || v.Group || v.Purpose != "Print version" {
+		t.Fatalf("want version command first, got %+v", v)
+	}
+	s := tree.Subcommands[1]
+	if s.Name != "server" || !s.Group || len(s.Subcommands) != 2 {
+		t.Fatalf("want server group second, got %+v", s)
+	}
+	if s.Subcommands[0].Name != "start" || s.Subcommands[1].Name != "stop" {
+		t.Fatalf("want sorted server subcommands, got %+v", s.Subcommands)
+	}
+
+	stdout.Reset()
+	if err := opts.Run(ctx, []Command{server, version}, []string{"commands", "-json", "server"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stdout.String(), "{\n  \"name\": \"server\",") {
+		t.Fatalf("want server subtree, got %q", stdout.String())
+	}
+}
+
+func TestCommandsAll(t *testing.T) {
+	ctx := context.Background()
+
+	start := NewCommand("start", nil, nil, "Start the server")
+	stop := NewCommand("stop", nil, nil, "Stop the server")
+	server := NewGroup("server", "Server operations", start, stop)
+	list := NewCommand("list", nil, nil, "")
+	jobs := NewGroup("jobs", "Manage jobs", list)
+
+	var stdout strings.Builder
+	opts := &Options{Stdout: &stdout}
+	if err := opts.Run(ctx, []Command{server, jobs}, []string{"commands", "-all"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"\tjobs             Manage jobs\n" +
+		"\t  jobs list\n" +
+		"\tserver           Server operations\n" +
+		"\t  server start   Start the server\n" +
+		"\t  server stop    Stop the server\n"
+	if got := stdout.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+
+	stdout.Reset()
+	if err := opts.Run(ctx, []Command{server, jobs}, []string{"commands", "-recursive", "server"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\tserver start     Start the server\n\tserver stop      Stop the server\n"; stdout.String() != want {
+		t.Fatalf("want %q, got %q", want, stdout.String())
+	}
+}
+
+func TestHelpAll(t *testing.T) {
+	ctx := context.Background()
+
+	start := NewCommand("start", nil, nil, "Start the server")
+	stop := NewCommand("stop", nil, nil, "Stop the server")
+	backup := NewCommand("backup", nil, nil, "Backup the database")
+	db := NewGroup("db", "Database operations", backup)
+	server := NewGroup("server", "Server operations", stop, start, db)
+	version := NewCommand("version", nil, nil, "Print version")
+	cmds := []Command{server, version}
+
+	for _, arg := range []string{"-a", "-all"} {
+		stdout, _, err := RunCapture(ctx, cmds, []string{"help", arg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "Commands:\n" +
+			"\tversion           Print version\n" +
+			"\tserver start      Start the server\n" +
+			"\tserver stop       Stop the server\n" +
+			"\tserver db backup  Backup the database\n"
+		if stdout != want {
+			t.Fatalf("help %s: got %q, want %q", arg, stdout, want)
+		}
+	}
+
+	// The alternate names are aliases of a single flag.
+	gc := new(groupCmd)
+	for special, want := range map[string][]string{"help": {"all"}, "commands": {"json", "recursive"}} {
+		var names []string
+		for _, f := range allFlags(gc.newSpecialFlags(special)) {
+			names = append(names, f.Name)
+		}
+		if !slices.Equal(names, want) {
+			t.Errorf("%s: want flags %q, got %q", special, want, names)
+		}
+	}
+}
+
+func TestPrintTree(t *testing.T) {
+	start := NewCommand("start", nil, nil, "Start the server")
+	stop := NewCommand("stop", nil, nil, "Stop the server")
+	server := NewGroup("server", "Server operations", stop, start)
+	version := NewCommand("version", nil, nil, "")
+
+	var sb strings.Builder
+	PrintTree(&sb, []Command{server, version})
+	want := "├── version\n" +
+		"└── server  Server operations\n" +
+		"    ├── start  Start the server\n" +
+		"    └── stop  Stop the server\n"
+	if sb.String() != want {
+		t.Fatalf("got %q, want %q", sb.String(), want)
+	}
+}